@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"taskmaster/control"
+)
+
+type peerCredentialsKey struct{}
+
+// Keep the credentials of the peer of a UNIX socket connection in the
+// context of its requests
+func withPeerCredentials(ctx context.Context, conn net.Conn) context.Context {
+	if peer, err := control.GetPeerCredentials(conn); err == nil {
+		return context.WithValue(ctx, peerCredentialsKey{}, peer)
+	}
+	return ctx
+}
+
+// Only let read-only clients send GET requests, and operators send any
+// request
+func (this *Server) authorize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role := control.ROLE_NONE
+		if peer, ok := r.Context().Value(peerCredentialsKey{}).(control.PeerCredentials); ok {
+			role = control.Role(this.manager.Get().Acl, peer)
+		}
+		allowed := role == control.ROLE_OPERATOR ||
+			(role == control.ROLE_READ_ONLY && (r.Method == http.MethodGet || r.Method == http.MethodHead))
+		if !allowed {
+			writeError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Host names a TCP listener answers to besides its own address, the ones a
+// DNS rebinding attack cannot point to another server
+var LOOPBACK_HOSTS = []string{"localhost", "127.0.0.1", "::1"}
+
+// Whether the Host header `host` names the TCP listener at `address`: a
+// page whose domain was rebound to the listener sends its own domain
+func isListenHost(host string, address net.Addr) bool {
+	tcpAddress, ok := address.(*net.TCPAddr)
+	if !ok {
+		// Browsers cannot reach UNIX sockets
+		return true
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = strings.Trim(host, "[]"), "80"
+	}
+	if port != strconv.Itoa(tcpAddress.Port) {
+		return false
+	}
+	if slices.Contains(LOOPBACK_HOSTS, hostname) {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && !tcpAddress.IP.IsUnspecified() && ip.Equal(tcpAddress.IP)
+}
+
+// Reject what a browser may send on behalf of another site: the requests
+// naming another host than the listener at `address`, and the ones changing
+// something that come from another origin or are forms, whose content types
+// are sent without asking the server first
+func checkOrigin(handler http.Handler, address net.Addr) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isListenHost(r.Host, address) {
+			writeError(w, http.StatusForbidden, "unknown host: "+r.Host)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if origin := r.Header.Get("Origin"); len(origin) != 0 {
+				if parsed, err := url.Parse(origin); err != nil || parsed.Host != r.Host {
+					writeError(w, http.StatusForbidden, "cross-origin requests are not allowed")
+					return
+				}
+			}
+			if contentType := r.Header.Get("Content-Type"); len(contentType) != 0 {
+				if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "application/json" {
+					writeError(w, http.StatusUnsupportedMediaType, "only JSON requests are accepted")
+					return
+				}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// Assets of the web dashboard, served under /dashboard/
+//
+//go:embed dashboard
+var dashboard embed.FS
+
+func dashboardHandler() http.Handler {
+	assets, _ := fs.Sub(dashboard, "dashboard")
+	return http.FileServerFS(assets)
+}
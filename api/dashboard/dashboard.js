@@ -0,0 +1,161 @@
+"use strict";
+
+// Paths are relative to /dashboard/, so that the dashboard keeps working
+// behind a reverse proxy serving taskmaster under a prefix.
+const API = "..";
+const OUTPUT_LINES = 200;
+const OUTPUT_REFRESH_INTERVAL = 2000;
+
+const tasksElement = document.getElementById("tasks");
+const messageElement = document.getElementById("message");
+const connectionElement = document.getElementById("connection");
+const outputElement = document.getElementById("output");
+const outputTitleElement = document.getElementById("output-title");
+const outputLinesElement = document.getElementById("output-lines");
+
+// Process whose output is shown, if any
+let tailed = null;
+let refreshTimeout = null;
+
+function element(tag, properties, children) {
+  const result = Object.assign(document.createElement(tag), properties);
+  result.append(...(children || []));
+  return result;
+}
+
+function showMessage(text, success) {
+  messageElement.textContent = text;
+  messageElement.className = success ? "success" : "";
+  messageElement.hidden = false;
+}
+
+async function request(method, path) {
+  const response = await fetch(`${API}${path}`, { method });
+  const body = await response.json();
+  if (!response.ok) {
+    throw new Error(body.reason || body.error || response.statusText);
+  }
+  return body;
+}
+
+function statusClass(status) {
+  if (status.startsWith("RUNNING")) return "running";
+  if (status.startsWith("STARTING")) return "starting";
+  if (status.startsWith("FAILURE") || status.startsWith("FAILED")) return "failed";
+  return "";
+}
+
+async function action(task, process, name) {
+  try {
+    await request("POST", `/tasks/${encodeURIComponent(task)}/processes/${process}/${name}`);
+    showMessage(`${task} ${process}: ${name} requested`, true);
+  } catch (error) {
+    showMessage(`${task} ${process}: ${error.message}`);
+  }
+  scheduleRefresh();
+}
+
+function renderProcess(task, process) {
+  const button = (label, onclick) => element("button", { textContent: label, onclick });
+  return element("tr", {}, [
+    element("td", { textContent: process.id }),
+    element("td", { className: `status ${statusClass(process.status)}`, textContent: process.status }),
+    element("td", { className: "actions" }, [
+      button("Start", () => action(task.name, process.id, "start")),
+      button("Stop", () => action(task.name, process.id, "stop")),
+      button("Restart", () => action(task.name, process.id, "restart")),
+      button("Output", () => tail(task.name, process.id)),
+    ]),
+  ]);
+}
+
+function renderTask(task) {
+  return element("section", { className: "task" }, [
+    element("h2", { textContent: task.name }),
+    element("table", {}, [
+      element("tbody", {}, task.processes.map((process) => renderProcess(task, process))),
+    ]),
+  ]);
+}
+
+async function refresh() {
+  try {
+    const tasks = await request("GET", "/tasks");
+    tasksElement.replaceChildren(...tasks.map(renderTask));
+  } catch (error) {
+    showMessage(`Failed to get tasks: ${error.message}`);
+  }
+}
+
+// Refresh once for a burst of events
+function scheduleRefresh() {
+  clearTimeout(refreshTimeout);
+  refreshTimeout = setTimeout(refresh, 100);
+}
+
+async function refreshOutput() {
+  if (tailed === null) {
+    return;
+  }
+  const { task, process } = tailed;
+  try {
+    const lines = await request("GET", `/tasks/${encodeURIComponent(task)}/processes/${process}/output?lines=${OUTPUT_LINES}`);
+    const atBottom = outputLinesElement.scrollTop + outputLinesElement.clientHeight >= outputLinesElement.scrollHeight - 5;
+    outputLinesElement.replaceChildren(...lines.map((line) =>
+      element("div", { className: line.stream, textContent: line.text })
+    ));
+    if (atBottom) {
+      outputLinesElement.scrollTop = outputLinesElement.scrollHeight;
+    }
+  } catch (error) {
+    outputLinesElement.textContent = `Failed to get output: ${error.message}`;
+  }
+}
+
+function tail(task, process) {
+  tailed = { task, process };
+  outputTitleElement.textContent = `${task} ${process}`;
+  outputLinesElement.replaceChildren();
+  outputElement.hidden = false;
+  refreshOutput();
+}
+
+function listen() {
+  const source = new EventSource(`${API}/events`);
+  source.onopen = () => {
+    connectionElement.textContent = "connected";
+    connectionElement.className = "connected";
+    scheduleRefresh();
+  };
+  source.onerror = () => {
+    connectionElement.textContent = "disconnected";
+    connectionElement.className = "disconnected";
+  };
+  source.addEventListener("process", scheduleRefresh);
+  source.addEventListener("reload", (event) => {
+    const data = JSON.parse(event.data);
+    if (data.error) {
+      showMessage(`Reload failed: ${data.error}`);
+    }
+    scheduleRefresh();
+  });
+}
+
+document.getElementById("reload").onclick = async () => {
+  try {
+    await request("POST", "/reload");
+    showMessage("Configuration reloaded", true);
+  } catch (error) {
+    showMessage(`Reload failed: ${error.message}`);
+  }
+  scheduleRefresh();
+};
+
+document.getElementById("output-close").onclick = () => {
+  tailed = null;
+  outputElement.hidden = true;
+};
+
+setInterval(refreshOutput, OUTPUT_REFRESH_INTERVAL);
+refresh();
+listen();
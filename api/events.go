@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"taskmaster/events"
+)
+
+// Longest time a long-polling request waits for new events
+const MAX_POLL_TIMEOUT = 60 * time.Second
+
+func parseSequence(value string) (uint64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// Filter events on the `task` query parameter, if any
+func taskFilter(r *http.Request) func(events.Event) bool {
+	task := r.URL.Query().Get("task")
+	return func(event events.Event) bool {
+		return len(task) == 0 || event.Task == task
+	}
+}
+
+// Server-sent event stream of the process and reload events. Clients
+// reconnecting with a Last-Event-ID header first receive the events
+// they missed.
+func (this *Server) getEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	lastId, err := parseSequence(r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid Last-Event-ID header")
+		return
+	}
+	filter := taskFilter(r)
+
+	stream, unsubscribe := this.events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event events.Event) {
+		if event.Sequence <= lastId || !filter(event) {
+			return
+		}
+		lastId = event.Sequence
+		name := "process"
+		if len(event.Task) == 0 {
+			name = "reload"
+		}
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Sequence, name, data)
+	}
+
+	if len(r.Header.Get("Last-Event-ID")) != 0 {
+		for _, event := range this.events.Since(lastId) {
+			send(event)
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			send(event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		case <-this.closing:
+			return
+		}
+	}
+}
+
+// Long-polling alternative to the event stream: answer with the events
+// following the `since` sequence number, waiting up to `timeout` for
+// at least one of them.
+func (this *Server) pollEvents(w http.ResponseWriter, r *http.Request) {
+	since, err := parseSequence(r.URL.Query().Get("since"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid since parameter")
+		return
+	}
+	timeout := 30 * time.Second
+	if value := r.URL.Query().Get("timeout"); len(value) != 0 {
+		if timeout, err = time.ParseDuration(value); err != nil || timeout < 0 {
+			writeError(w, http.StatusBadRequest, "invalid timeout parameter")
+			return
+		}
+	}
+	timeout = min(timeout, MAX_POLL_TIMEOUT)
+	filter := taskFilter(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-this.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	result := []events.Event{}
+	for len(result) == 0 && ctx.Err() == nil {
+		for _, event := range this.events.Wait(ctx, since) {
+			since = event.Sequence
+			if filter(event) {
+				result = append(result, event)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"taskmaster/messages/helpers"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+	taskOutput "taskmaster/messages/task/output"
+)
+
+type processView struct {
+	Id     uint   `json:"id"`
+	Status string `json:"status"`
+}
+
+type taskView struct {
+	Id        uint          `json:"id"`
+	Name      string        `json:"name"`
+	Processes []processView `json:"processes"`
+}
+
+type resultView struct {
+	Success bool   `json:"success"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type errorView struct {
+	Error string `json:"error"`
+}
+
+func newTaskView(task taskOutput.Status) taskView {
+	view := taskView{Id: task.TaskId(), Name: task.Name(), Processes: []processView{}}
+	for _, proc := range task.Processes() {
+		view.Processes = append(view.Processes, processView{proc.ProcessId(), proc.Value()})
+	}
+	return view
+}
+
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, errorView{message})
+}
+
+// Answer with the outcome of a request that either succeeds or fails
+func writeResult(w http.ResponseWriter, res output.Message) {
+	if failure, ok := res.(helpers.Failure); ok {
+		writeJSON(w, http.StatusConflict, resultView{Success: false, Reason: failure.Reason()})
+	} else {
+		writeJSON(w, http.StatusOK, resultView{Success: true})
+	}
+}
+
+func (this *Server) request(w http.ResponseWriter, req input.Message) (output.Message, bool) {
+	res, ok := this.dispatcher.Request(req)
+	if !ok {
+		writeError(w, http.StatusServiceUnavailable, "taskmaster is shutting down")
+		return nil, false
+	}
+	if _, isBadRequest := res.(output.BadRequest); isBadRequest {
+		writeError(w, http.StatusBadRequest, "invalid request")
+		return nil, false
+	}
+	return res, true
+}
+
+func (this *Server) tasks(w http.ResponseWriter) ([]taskOutput.Status, bool) {
+	if res, ok := this.request(w, input.NewStatus()); !ok {
+		return nil, false
+	} else {
+		return res.(output.Status).Tasks(), true
+	}
+}
+
+// Find the task named after the {name} path parameter
+func (this *Server) task(w http.ResponseWriter, r *http.Request) (taskOutput.Status, bool) {
+	tasks, ok := this.tasks(w)
+	if !ok {
+		return nil, false
+	}
+	name := r.PathValue("name")
+	for _, task := range tasks {
+		if task.Name() == name {
+			return task, true
+		}
+	}
+	writeError(w, http.StatusNotFound, fmt.Sprintf("no task named %s", name))
+	return nil, false
+}
+
+// Find the task and process designated by the {name} and {id} path parameters
+func (this *Server) process(w http.ResponseWriter, r *http.Request) (taskOutput.Status, uint, bool) {
+	task, ok := this.task(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+	id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+	if err != nil || id >= uint64(len(task.Processes())) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("task %s has no process %s", task.Name(), r.PathValue("id")))
+		return nil, 0, false
+	}
+	return task, uint(id), true
+}
+
+func (this *Server) getTasks(w http.ResponseWriter, r *http.Request) {
+	if tasks, ok := this.tasks(w); ok {
+		views := []taskView{}
+		for _, task := range tasks {
+			views = append(views, newTaskView(task))
+		}
+		writeJSON(w, http.StatusOK, views)
+	}
+}
+
+func (this *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	if task, ok := this.task(w, r); ok {
+		writeJSON(w, http.StatusOK, newTaskView(task))
+	}
+}
+
+func (this *Server) getProcess(w http.ResponseWriter, r *http.Request) {
+	if task, id, ok := this.process(w, r); ok {
+		writeJSON(w, http.StatusOK, newTaskView(task).Processes[id])
+	}
+}
+
+// Handler for the process actions, `newRequest` builds the request
+// to send to the master from the task name and process id. The task is
+// designated by its name, for a reload reordering the tasks meanwhile not
+// to change which one it is.
+func (this *Server) processAction(newRequest func(taskName string, processId uint) input.Message) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if task, id, ok := this.process(w, r); ok {
+			if res, ok := this.request(w, newRequest(task.Name(), id)); ok {
+				writeResult(w, res)
+			}
+		}
+	}
+}
+
+func (this *Server) postReload(w http.ResponseWriter, r *http.Request) {
+	if res, ok := this.request(w, input.NewReload()); ok {
+		if failure, isFailure := res.(output.ReloadFailure); isFailure {
+			writeJSON(w, http.StatusUnprocessableEntity, resultView{Success: false, Reason: failure.Reason()})
+		} else {
+			writeResult(w, res)
+		}
+	}
+}
+
+// Recent output of a process, limited to the last `lines` lines if set
+func (this *Server) getOutput(w http.ResponseWriter, r *http.Request) {
+	count := 0
+	if value := r.URL.Query().Get("lines"); len(value) != 0 {
+		if parsed, err := strconv.ParseUint(value, 10, 31); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid number of lines: %s", value))
+			return
+		} else {
+			count = int(parsed)
+		}
+	}
+	if task, id, ok := this.process(w, r); ok {
+		writeJSON(w, http.StatusOK, this.logs.Get(task.Name(), id).Lines(count))
+	}
+}
+
+func (this *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	this.metrics.Write(w)
+}
+
+func (this *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /tasks", this.getTasks)
+	mux.HandleFunc("GET /tasks/{name}", this.getTask)
+	mux.HandleFunc("GET /tasks/{name}/processes/{id}", this.getProcess)
+	mux.HandleFunc("GET /tasks/{name}/processes/{id}/output", this.getOutput)
+	mux.HandleFunc("POST /tasks/{name}/processes/{id}/start", this.processAction(
+		func(taskName string, processId uint) input.Message {
+			return input.NewStartProcessByName(taskName, processId)
+		},
+	))
+	mux.HandleFunc("POST /tasks/{name}/processes/{id}/stop", this.processAction(
+		func(taskName string, processId uint) input.Message {
+			return input.NewStopProcessByName(taskName, processId)
+		},
+	))
+	mux.HandleFunc("POST /tasks/{name}/processes/{id}/restart", this.processAction(
+		func(taskName string, processId uint) input.Message {
+			return input.NewRestartProcessByName(taskName, processId)
+		},
+	))
+	mux.HandleFunc("POST /reload", this.postReload)
+	mux.HandleFunc("GET /events", this.getEvents)
+	mux.HandleFunc("GET /events/poll", this.pollEvents)
+	mux.HandleFunc("GET /metrics", this.getMetrics)
+	mux.Handle("GET /dashboard/", http.StripPrefix("/dashboard/", dashboardHandler()))
+	mux.Handle("GET /{$}", http.RedirectHandler("/dashboard/", http.StatusFound))
+
+	return mux
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/control"
+	"taskmaster/events"
+	"taskmaster/logs"
+	"taskmaster/metrics"
+)
+
+// HTTP server exposing the master runner as a REST API
+type Server struct {
+	Address string
+
+	dispatcher *control.Dispatcher
+	events     *events.Broker
+	metrics    *metrics.Collector
+	logs       *logs.Store
+	manager    config.Manager
+	listener   net.Listener
+	server     *http.Server
+	closing    chan struct{}
+}
+
+type ServerError struct {
+	cause string
+}
+
+func (this ServerError) Error() string {
+	return fmt.Sprintf("HTTP API error: %s", this.cause)
+}
+
+func newServerError(cause string) ServerError {
+	return ServerError{cause}
+}
+
+// Listen on `address`, which is either a TCP address (host:port) or the
+// path of a UNIX socket prefixed with "unix:". Requests on a UNIX socket
+// are authorized with the ACL of the configuration of `manager`, and the
+// requests changing something are only accepted from the dashboard or from
+// clients that are not browsers.
+func Listen(address string, dispatcher *control.Dispatcher, broker *events.Broker, collector *metrics.Collector, store *logs.Store, manager config.Manager) (*Server, error) {
+	var listener net.Listener
+	var err error
+
+	path, isUnix := strings.CutPrefix(address, "unix:")
+	if isUnix {
+		if err := control.RemoveStaleSocket(path); err != nil {
+			return nil, newServerError(err.Error())
+		}
+		listener, err = net.Listen("unix", path)
+	} else {
+		listener, err = net.Listen("tcp", address)
+	}
+	if err != nil {
+		return nil, newServerError(err.Error())
+	}
+	if isUnix {
+		if err := os.Chmod(path, control.SocketMode(manager.Get().Acl)); err != nil {
+			listener.Close()
+			return nil, newServerError(err.Error())
+		}
+		manager.Subscribe(func(_, conf *config.Config) error {
+			if err := os.Chmod(path, control.SocketMode(conf.Acl)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return newServerError(err.Error())
+			}
+			return nil
+		})
+	}
+
+	instance := &Server{
+		Address:    address,
+		dispatcher: dispatcher,
+		events:     broker,
+		metrics:    collector,
+		logs:       store,
+		manager:    manager,
+		listener:   listener,
+		closing:    make(chan struct{}),
+	}
+	instance.server = &http.Server{Handler: checkOrigin(instance.routes(), listener.Addr())}
+	if isUnix {
+		instance.server.Handler = instance.authorize(instance.server.Handler)
+		instance.server.ConnContext = withPeerCredentials
+	}
+	return instance, nil
+}
+
+// Serve requests until the server is closed
+func (this *Server) Serve() error {
+	if err := this.server.Serve(this.listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return newServerError(err.Error())
+	}
+	return nil
+}
+
+func (this *Server) Close() error {
+	// Let the event streams end, so that they don't hold the shutdown
+	close(this.closing)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return this.server.Shutdown(ctx)
+}
@@ -0,0 +1,305 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"taskmaster/atom"
+	"taskmaster/config"
+	"taskmaster/control"
+	"taskmaster/events"
+	"taskmaster/logs"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+	processOutput "taskmaster/messages/process/output"
+	taskOutput "taskmaster/messages/task/output"
+	"taskmaster/metrics"
+	"taskmaster/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testManager struct {
+	config *config.Config
+}
+
+func (this testManager) Get() *config.Config                               { return this.config }
+func (this testManager) Subscribe(atom.AtomSubscriberFunc[*config.Config]) {}
+func (this testManager) Load() error                                       { return nil }
+func (this testManager) Plan() (config.ReloadPlan, error)                  { return config.ReloadPlan{}, nil }
+
+// Stand-in for the master, answering with a single task "web" running one
+// process, and reporting the requests it receives on `received`
+func runTestMaster(requests <-chan input.Message, responses chan<- output.Message, received chan<- input.Message) {
+	defer close(responses)
+	for req := range requests {
+		received <- req
+		switch req := req.(type) {
+		case input.Status:
+			responses <- output.NewStatus([]taskOutput.Status{
+				taskOutput.NewStatus(0, "web", []processOutput.Status{processOutput.NewStatus(0, "RUNNING")}),
+			})
+		case input.RestartProcess:
+			responses <- output.NewRestartProcessSuccess(req.TaskId(), req.TaskName(), req.ProcessId())
+		case input.StopProcess:
+			responses <- output.NewStopProcessFailure(req.TaskId(), req.TaskName(), req.ProcessId(), "process is not running")
+		case input.Reload:
+			responses <- output.NewReloadFailure("No task to run")
+		default:
+			responses <- output.NewBadRequest()
+		}
+	}
+}
+
+func newTestServer(t *testing.T, acl []config.AclRule) (*Server, <-chan input.Message) {
+	requests, responses := make(chan input.Message), make(chan output.Message)
+	received := make(chan input.Message, 16)
+	go runTestMaster(requests, responses, received)
+
+	manager := testManager{&config.Config{Acl: acl}}
+	broker := events.NewBroker(16)
+	instance := &Server{
+		dispatcher: control.NewDispatcher(requests, responses),
+		events:     broker,
+		metrics:    metrics.NewCollector(manager, broker),
+		logs:       logs.NewStore(16, nil),
+		manager:    manager,
+		closing:    make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		close(instance.closing)
+		close(requests)
+	})
+	return instance, received
+}
+
+// Serve the routes of `instance` the way they are served over TCP
+func serve(t *testing.T, instance *Server) *httptest.Server {
+	server := httptest.NewUnstartedServer(nil)
+	server.Config.Handler = checkOrigin(instance.routes(), server.Listener.Addr())
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetTasks(t *testing.T) {
+	instance, _ := newTestServer(t, nil)
+	server := serve(t, instance)
+
+	res, err := http.Get(server.URL + "/tasks")
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	tasks := []taskView{}
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&tasks))
+	require.Equal(t, []taskView{{Id: 0, Name: "web", Processes: []processView{{0, "RUNNING"}}}}, tasks)
+
+	res, err = http.Get(server.URL + "/tasks/api")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = http.Get(server.URL + "/tasks/web/processes/1")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestProcessActions(t *testing.T) {
+	instance, received := newTestServer(t, nil)
+	server := serve(t, instance)
+
+	res, err := http.Post(server.URL+"/tasks/web/processes/0/restart", "application/json", nil)
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Implements(t, (*input.Status)(nil), <-received)
+	// The task is designated by its name, not by the id it had in the status
+	require.Equal(t, "web", (<-received).(input.RestartProcess).TaskName())
+
+	res, err = http.Post(server.URL+"/tasks/web/processes/0/stop", "application/json", nil)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusConflict, res.StatusCode)
+	result := resultView{}
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&result))
+	require.Equal(t, resultView{Success: false, Reason: "process is not running"}, result)
+
+	res, err = http.Post(server.URL+"/reload", "", nil)
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+}
+
+func TestCheckOrigin(t *testing.T) {
+	instance, _ := newTestServer(t, nil)
+	server := serve(t, instance)
+	post := func(origin, contentType string) int {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/tasks/web/processes/0/restart", strings.NewReader("{}"))
+		require.Nil(t, err)
+		if len(origin) != 0 {
+			req.Header.Set("Origin", origin)
+		}
+		if len(contentType) != 0 {
+			req.Header.Set("Content-Type", contentType)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	require.Equal(t, http.StatusForbidden, post("http://example.com", ""))
+	require.Equal(t, http.StatusUnsupportedMediaType, post("", "application/x-www-form-urlencoded"))
+	require.Equal(t, http.StatusUnsupportedMediaType, post("", "text/plain"))
+	require.Equal(t, http.StatusOK, post(server.URL, ""))
+	require.Equal(t, http.StatusOK, post("", "application/json; charset=utf-8"))
+
+	// Reading is allowed from anywhere, the browsers keep the answer from
+	// the other sites
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/tasks", nil)
+	require.Nil(t, err)
+	req.Header.Set("Origin", "http://example.com")
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// A page whose domain was rebound to the listener is same-origin for
+	// the browser, but names its own domain as host
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+	send := func(method, host, origin string) int {
+		req, err := http.NewRequest(method, server.URL+"/tasks/web/processes/0/stop", nil)
+		require.Nil(t, err)
+		req.Host = host
+		if len(origin) != 0 {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	evil := fmt.Sprintf("evil.com:%d", port)
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, evil, "http://"+evil))
+	require.Equal(t, http.StatusForbidden, send(http.MethodGet, evil, ""))
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, fmt.Sprintf("127.0.0.1:%d", port+1), ""))
+	require.Equal(t, http.StatusForbidden, send(http.MethodPost, "localhost", ""))
+	localhost := fmt.Sprintf("localhost:%d", port)
+	require.Equal(t, http.StatusConflict, send(http.MethodPost, localhost, "http://"+localhost))
+	require.Equal(t, http.StatusConflict, send(http.MethodPost, fmt.Sprintf("[::1]:%d", port), ""))
+}
+
+func TestAuthorize(t *testing.T) {
+	const uid = 4242424
+	instance, _ := newTestServer(t, []config.AclRule{{Uid: utils.New(uint32(uid)), Role: control.ROLE_READ_ONLY}})
+	handler := instance.authorize(instance.routes())
+	request := func(method, path string, peer *control.PeerCredentials) int {
+		req := httptest.NewRequest(method, path, nil)
+		if peer != nil {
+			req = req.WithContext(context.WithValue(req.Context(), peerCredentialsKey{}, *peer))
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	readOnly := &control.PeerCredentials{Uid: uid, Gid: uid}
+	require.Equal(t, http.StatusOK, request(http.MethodGet, "/tasks", readOnly))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/reload", readOnly))
+	require.Equal(t, http.StatusForbidden, request(http.MethodGet, "/tasks", &control.PeerCredentials{Uid: uid + 1, Gid: uid + 1}))
+	require.Equal(t, http.StatusForbidden, request(http.MethodGet, "/tasks", nil))
+	require.Equal(t, http.StatusUnprocessableEntity, request(http.MethodPost, "/reload", &control.PeerCredentials{Uid: uint32(os.Getuid())}))
+}
+
+func TestListenKeepsOtherFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	require.Nil(t, os.WriteFile(path, []byte("data"), 0o644))
+
+	_, err := Listen("unix:"+path, nil, nil, nil, nil, testManager{&config.Config{}})
+	require.NotNil(t, err)
+	require.Equal(t, "HTTP API error: "+path+" exists and is not a socket", err.Error())
+	_, err = os.Stat(path)
+	require.Nil(t, err)
+}
+
+func TestPollEvents(t *testing.T) {
+	instance, _ := newTestServer(t, nil)
+	server := serve(t, instance)
+	instance.events.Publish(events.Event{Type: events.RELOAD_SUCCEEDED})
+	instance.events.Publish(events.Event{Type: events.RELOAD_FAILED, Task: "web"})
+
+	poll := func(query string) []events.Event {
+		res, err := http.Get(server.URL + "/events/poll?" + query)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		result := []events.Event{}
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&result))
+		return result
+	}
+
+	require.Len(t, poll("since=0"), 2)
+	require.Len(t, poll("since=1"), 1)
+	require.Equal(t, "web", poll("since=0&task=web")[0].Task)
+	require.Len(t, poll("since=2&timeout=10ms"), 0)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		instance.events.Publish(events.Event{Type: events.RELOAD_SUCCEEDED})
+	}()
+	require.Equal(t, uint64(3), poll("since=2&timeout=5s")[0].Sequence)
+
+	res, err := http.Get(server.URL + "/events/poll?timeout=soon")
+	require.Nil(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestEventStream(t *testing.T) {
+	instance, _ := newTestServer(t, nil)
+	server := serve(t, instance)
+	instance.events.Publish(events.Event{Type: events.RELOAD_SUCCEEDED})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	require.Nil(t, err)
+	// The events following the last one received are sent again
+	req.Header.Set("Last-Event-ID", "0")
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	reader := bufio.NewReader(res.Body)
+	readEvent := func() []string {
+		lines := []string{}
+		for {
+			line, err := reader.ReadString('\n')
+			require.Nil(t, err)
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+
+	missed := readEvent()
+	require.Equal(t, []string{"id: 1", "event: reload"}, missed[:2])
+
+	instance.events.Publish(events.Event{Type: "STARTED", Task: "web"})
+	live := readEvent()
+	require.Equal(t, []string{"id: 2", "event: process"}, live[:2])
+	require.Contains(t, live[2], `"task":"web"`)
+}
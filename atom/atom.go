@@ -19,7 +19,10 @@ type Atom[T any] interface {
 type atom[T any] struct {
 	subscribers []AtomSubscriberFunc[T]
 	lock        *sync.Mutex
-	value       *T
+	// Held by the updates for as long as their subscribers run, for each
+	// update to start from the value the previous one stored
+	updating *sync.Mutex
+	value    *T
 }
 
 // Get the underlying value
@@ -29,36 +32,48 @@ func (this *atom[T]) Get() T {
 	return *this.value
 }
 
+// Call the subscribers, and call the ones that succeeded again with the
+// values swapped if one of them fails, for them to undo the change
 func (this *atom[T]) triggerSubscribers(old *T, new *T) error {
-	for _, subscriber := range this.subscribers {
+	for i, subscriber := range this.subscribers {
 		if err := subscriber(*old, *new); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				this.subscribers[j](*new, *old)
+			}
 			return err
 		}
 	}
 	return nil
 }
 
-// Set the underlying value
+// Set the underlying value once every subscriber accepted it. If one fails,
+// the value is left unchanged and its error is returned.
 func (this *atom[T]) Set(value T) (T, error) {
-	this.lock.Lock()
-	old := *this.value
-	*this.value = value
-	this.lock.Unlock()
-	if err := this.triggerSubscribers(&old, &value); err != nil {
-		return old, err
-	}
-	return old, nil
+	return this.Update(func(T) T { return value })
 }
 
+// Same as Set, with a value computed from the current one. Updates happen
+// one at a time, the subscribers can Get the current value meanwhile.
 func (this *atom[T]) Update(updater func(T) T) (T, error) {
+	this.updating.Lock()
+	defer this.updating.Unlock()
+
 	this.lock.Lock()
 	old := *this.value
-	*this.value = updater(*this.value)
-	new := *this.value
+	new := updater(old)
+	if len(this.subscribers) == 0 {
+		*this.value = new
+		this.lock.Unlock()
+		return old, nil
+	}
 	this.lock.Unlock()
+
 	if err := this.triggerSubscribers(&old, &new); err != nil {
 		return old, err
 	}
+	this.lock.Lock()
+	*this.value = new
+	this.lock.Unlock()
 	return old, nil
 }
 
@@ -72,6 +87,7 @@ func NewAtom[T any](initialValue T) Atom[T] {
 		subscribers: []AtomSubscriberFunc[T]{},
 		value:       utils.New(initialValue),
 		lock:        new(sync.Mutex),
+		updating:    new(sync.Mutex),
 	}
 	return instance
 }
@@ -0,0 +1,62 @@
+package atom
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSubscriberFailure(t *testing.T) {
+	value := NewAtom(1)
+	applied := []int{}
+	value.Subscribe(func(_, new int) error {
+		applied = append(applied, new)
+		return nil
+	})
+	value.Subscribe(func(_, new int) error {
+		if new == 3 {
+			return errors.New("refused")
+		}
+		return nil
+	})
+
+	_, err := value.Set(2)
+	require.Nil(t, err)
+	_, err = value.Set(3)
+	require.NotNil(t, err)
+	require.Equal(t, "refused", err.Error())
+	require.Equal(t, 2, value.Get())
+	require.Equal(t, []int{2, 3, 2}, applied)
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	value := NewAtom(0)
+	seen := make(chan [2]int, 8)
+	value.Subscribe(func(old, new int) error {
+		time.Sleep(10 * time.Millisecond)
+		seen <- [2]int{old, new}
+		return nil
+	})
+
+	updates := new(sync.WaitGroup)
+	for range 4 {
+		updates.Add(1)
+		go func() {
+			defer updates.Done()
+			value.Update(func(old int) int { return old + 1 })
+		}()
+	}
+	updates.Wait()
+	close(seen)
+
+	// Each update starts from the value stored by the previous one
+	require.Equal(t, 4, value.Get())
+	previous := 0
+	for change := range seen {
+		require.Equal(t, [2]int{previous, previous + 1}, change)
+		previous++
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"taskmaster/config"
+)
+
+// Report every problem found in a configuration file and the files it
+// includes, exiting with 1 if some of them are errors
+func checkCommand(args []string, configPath string) int {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the problems as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check [-json] [config file (default: -config)]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+	if flags.NArg() == 1 {
+		configPath = flags.Arg(0)
+	}
+
+	diagnostics := config.Check(configPath)
+	if *asJSON {
+		data, _ := json.MarshalIndent(diagnostics, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		errors := 0
+		for _, diagnostic := range diagnostics {
+			fmt.Println(diagnostic)
+			if diagnostic.Severity == config.SEVERITY_ERROR {
+				errors++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%s: %d error(s), %d warning(s)\n", configPath, errors, len(diagnostics)-errors)
+	}
+
+	if config.HasErrors(diagnostics) {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"taskmaster/config"
+	"taskmaster/control"
+	"taskmaster/shell"
+	"taskmaster/utils"
+)
+
+const USAGE = `usage: taskmasterctl [-socket path] [command [arguments...]]
+
+Without a command, start an interactive prompt.
+
+`
+
+// Send a single command to the daemon, print the response and return
+// whether it succeeded.
+func execute(client *control.Client, cmd []string) bool {
+	if cmd[0] == "help" {
+		fmt.Println(shell.HELP_MESSAGE)
+		return true
+	}
+	req, err := shell.ParseCommand(cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	res, err := client.Request(req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	message, failed := shell.FormatResponse(res)
+	if failed {
+		fmt.Fprintln(os.Stderr, message)
+	} else if len(message) != 0 {
+		fmt.Println(message)
+	}
+	return !failed
+}
+
+func prompt(client *control.Client) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for fmt.Print("> "); scanner.Scan(); fmt.Print("> ") {
+		cmd := strings.Fields(scanner.Text())
+		if len(cmd) == 0 {
+			continue
+		}
+		if cmd[0] == "exit" || cmd[0] == "quit" {
+			return
+		}
+		execute(client, cmd)
+		if cmd[0] == "shutdown" {
+			return
+		}
+	}
+	fmt.Println()
+}
+
+func main() {
+	socketPath := flag.String("socket", config.DEFAULT_SOCKET, "path/to/taskmaster/control.sock")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), USAGE)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	client := utils.Must(control.Dial(*socketPath))
+	defer client.Close()
+
+	if flag.NArg() == 0 {
+		prompt(client)
+	} else if !execute(client, flag.Args()) {
+		client.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,369 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	SEVERITY_ERROR   = "error"
+	SEVERITY_WARNING = "warning"
+)
+
+// A problem found in a configuration file by Check
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Task     string `json:"task,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (this Diagnostic) String() string {
+	location := this.File
+	if this.Line != 0 {
+		location += fmt.Sprintf(":%d", this.Line)
+		if this.Column != 0 {
+			location += fmt.Sprintf(":%d", this.Column)
+		}
+	}
+	subject := ""
+	if len(this.Task) != 0 {
+		subject = fmt.Sprintf("task %s: ", this.Task)
+	}
+	if len(this.Path) != 0 {
+		subject += fmt.Sprintf("%s: ", this.Path)
+	}
+	return fmt.Sprintf("%s: %s: %s%s", location, this.Severity, subject, this.Message)
+}
+
+// Top-level properties that are checked with the tasks rather than on
+// their own
+var CHECKED_WITH_TASKS = []string{"tasks", "defaults", "include", "$schema"}
+
+type checker struct {
+	diagnostics []Diagnostic
+	// Files defining the tasks, by name
+	sources   map[string]string
+	taskCount int
+}
+
+// A configuration file being checked
+type checkedFile struct {
+	*checker
+	path      string
+	positions positions
+}
+
+func (this *checkedFile) report(severity, task, path, message string) {
+	position := this.positions.get(path)
+	this.diagnostics = append(this.diagnostics, Diagnostic{
+		Severity: severity,
+		File:     this.path,
+		Line:     position.Line,
+		Column:   position.Column,
+		Task:     task,
+		Path:     path,
+		Message:  message,
+	})
+}
+
+func (this *checkedFile) error(task, path string, err error) {
+	this.report(SEVERITY_ERROR, task, path, err.Error())
+}
+
+func (this *checkedFile) warn(task, path, format string, args ...any) {
+	this.report(SEVERITY_WARNING, task, path, fmt.Sprintf(format, args...))
+}
+
+// Decode a configuration file of any format into a generic document, with
+// the positions of its properties
+func (this *checkedFile) decode() (document any, ok bool) {
+	data, err := os.ReadFile(this.path)
+	if err != nil {
+		this.error("", "", err)
+		return nil, false
+	}
+
+	switch filepath.Ext(this.path) {
+	case ".json":
+		var syntaxErr *json.SyntaxError
+		if err := json.Unmarshal(data, &document); errors.As(err, &syntaxErr) {
+			position := offsetPosition(data, int(syntaxErr.Offset))
+			this.diagnostics = append(this.diagnostics, Diagnostic{
+				Severity: SEVERITY_ERROR, File: this.path, Line: position.Line, Column: position.Column, Message: err.Error(),
+			})
+			return nil, false
+		} else if err != nil {
+			this.error("", "", err)
+			return nil, false
+		}
+		this.positions = jsonPositions(data)
+
+	case ".yaml", ".yml":
+		root, _, err := yamlToJSON(data)
+		if err != nil {
+			this.error("", "", err)
+			return nil, false
+		}
+		root.Decode(&document)
+		this.positions = yamlPositions(root)
+
+	case ".toml":
+		document, _, err = tomlToJSON(data)
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			this.diagnostics = append(this.diagnostics, Diagnostic{
+				Severity: SEVERITY_ERROR, File: this.path, Line: parseErr.Position.Line, Column: parseErr.Position.Col, Message: parseErr.Message,
+			})
+			return nil, false
+		} else if err != nil {
+			this.error("", "", err)
+			return nil, false
+		}
+		this.positions = tomlPositions(data)
+
+	case ".conf", ".ini":
+		imported, warnings, err := ImportSupervisord(this.path)
+		if err != nil {
+			this.error("", "", err)
+			return nil, false
+		}
+		for _, warning := range warnings {
+			this.warn("", "", "%s", warning)
+		}
+		converted, _ := json.Marshal(imported)
+		json.Unmarshal(converted, &document)
+
+	default:
+		this.error("", "", errors.New("Invalid config file format (expected a json, yaml, yml, toml, conf or ini file)"))
+		return nil, false
+	}
+
+	if document == nil {
+		document = map[string]any{}
+	}
+	if _, ok := document.(map[string]any); !ok {
+		this.error("", "", errors.New("the configuration must be an object"))
+		return nil, false
+	}
+	return document, true
+}
+
+// Check the top-level properties other than the tasks, one by one
+func (this *checkedFile) checkProperties(document map[string]any) {
+	data, _ := json.Marshal(document)
+	for _, err := range checkPropertyNames(data, reflect.TypeFor[Config]()) {
+		this.error("", errorProperty(err), err)
+	}
+	known := propertyNames(reflect.TypeFor[Config]())
+	for _, key := range slices.Sorted(maps.Keys(document)) {
+		if !slices.Contains(known, key) {
+			continue
+		}
+		if slices.ContainsFunc(CHECKED_WITH_TASKS, func(property string) bool { return strings.EqualFold(property, key) }) {
+			continue
+		}
+		// Check the items of arrays one by one too
+		items, isArray := document[key].([]any)
+		if !isArray {
+			items = []any{document[key]}
+		}
+		for i, item := range items {
+			path := key
+			value := item
+			if isArray {
+				path = indexPath(key, i)
+				value = []any{item}
+			}
+			data, _ := json.Marshal(map[string]any{key: value})
+			if err := json.Unmarshal(data, &Config{}); err != nil {
+				if property := errorProperty(err); len(property) != 0 && property != key {
+					path = joinPath(path, property)
+				}
+				this.error("", path, err)
+			}
+		}
+	}
+}
+
+// Check the tasks of a configuration file, with `inherited` beneath its
+// defaults, and get its defaults
+func (this *checkedFile) checkTasks(document map[string]any, inherited map[string]any) map[string]any {
+	resolveEnvironmentFiles(document, filepath.Dir(this.path))
+	defaults, err := resolveDefaults(document, inherited)
+	if err != nil {
+		this.error("", joinPath("defaults", errorProperty(err)), err)
+		defaults = inherited
+	}
+
+	value, ok := lookup(document, "tasks")
+	if !ok {
+		return defaults
+	}
+	tasks, ok := value.([]any)
+	if !ok {
+		this.error("", "tasks", fmt.Errorf("expected an array of tasks, got %v", value))
+		return defaults
+	}
+
+	resolved, resolveErrs := resolveEachTask(tasks, defaults)
+	for i, value := range tasks {
+		path := indexPath("tasks", i)
+		name := ""
+		if object, ok := value.(map[string]any); ok {
+			if value, ok := lookup(object, "name"); ok {
+				name = fmt.Sprint(value)
+			}
+		}
+		if resolveErrs[i] != nil {
+			this.error(name, joinPath(path, errorPath(resolveErrs[i])), resolveErrs[i])
+			continue
+		}
+
+		var task Task
+		data, _ := json.Marshal(resolved[i])
+		errs := task.decode(data)
+		for _, err := range errs {
+			this.error(name, joinPath(path, errorPath(err)), err)
+		}
+		if len(errs) != 0 {
+			continue
+		}
+
+		this.taskCount++
+		if other, ok := this.sources[name]; ok {
+			this.error(name, joinPath(path, "name"), fmt.Errorf("Multiple tasks with the same name: %s (in %s and %s)", name, other, this.path))
+		} else {
+			this.sources[name] = this.path
+		}
+		this.checkTask(&task, value.(map[string]any), path)
+	}
+	return defaults
+}
+
+// Report the settings of a valid task that are likely mistakes, `raw` being
+// the task as written in the file
+func (this *checkedFile) checkTask(task *Task, raw map[string]any, path string) {
+	if !isTemplate(*task.Command) {
+		if _, err := task.LookPath(); err != nil {
+			this.warn(*task.Name, joinPath(path, "command"), "%s", err)
+		}
+	}
+	if _, ok := lookup(raw, "restartAttempts"); ok && task.Restart == "never" {
+		this.warn(*task.Name, joinPath(path, "restartAttempts"), "restartAttempts has no effect when restart is 'never'")
+	}
+	if len(task.StdoutLogFile) != 0 && task.Stdout == "ignore" {
+		this.warn(*task.Name, joinPath(path, "stdoutLogFile"), "stdoutLogFile has no effect when stdout is 'ignore'")
+	}
+	if len(task.StderrLogFile) != 0 && task.Stderr == "ignore" {
+		this.warn(*task.Name, joinPath(path, "stderrLogFile"), "stderrLogFile has no effect when stderr is 'ignore'")
+	}
+}
+
+// Check the files matching the include globs of the configuration
+func (this *checkedFile) checkIncludes(document map[string]any, defaults map[string]any) {
+	value, ok := lookup(document, "include")
+	if !ok {
+		return
+	}
+	patterns, ok := value.([]any)
+	if !ok {
+		this.error("", "include", fmt.Errorf("expected an array of glob patterns, got %v", value))
+		return
+	}
+
+	for i, value := range patterns {
+		path := indexPath("include", i)
+		pattern, ok := value.(string)
+		if !ok {
+			this.error("", path, fmt.Errorf("expected a glob pattern, got %v", value))
+			continue
+		}
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(this.path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			this.error("", path, fmt.Errorf("Invalid include pattern %s (%s)", pattern, err))
+			continue
+		}
+		if len(matches) == 0 {
+			this.warn("", path, "no file matches %s", pattern)
+		}
+
+		for _, match := range matches {
+			if same, err := sameFile(match, this.path); err != nil || same {
+				continue
+			}
+			fragment := &checkedFile{checker: this.checker, path: match, positions: positions{}}
+			if document, ok := fragment.decode(); ok {
+				fragment.checkTasks(document.(map[string]any), defaults)
+			}
+		}
+	}
+}
+
+// Check the configuration file at `path` and the files it includes,
+// reporting every problem found instead of stopping at the first one like
+// Parse does
+func Check(path string) []Diagnostic {
+	root := &checkedFile{
+		checker:   &checker{diagnostics: []Diagnostic{}, sources: map[string]string{}},
+		path:      path,
+		positions: positions{},
+	}
+	value, ok := root.decode()
+	if !ok {
+		return root.diagnostics
+	}
+	document := value.(map[string]any)
+
+	root.checkProperties(document)
+	defaults := root.checkTasks(document, map[string]any{})
+	root.checkIncludes(document, defaults)
+
+	if root.taskCount == 0 && !root.hasErrors() {
+		root.error("", "", errors.New("No task to run"))
+	}
+
+	// In the order of the files, and of their lines
+	files := []string{}
+	for _, diagnostic := range root.diagnostics {
+		if !slices.Contains(files, diagnostic.File) {
+			files = append(files, diagnostic.File)
+		}
+	}
+	slices.SortStableFunc(root.diagnostics, func(a, b Diagnostic) int {
+		if a.File != b.File {
+			return slices.Index(files, a.File) - slices.Index(files, b.File)
+		}
+		if a.Line != b.Line {
+			return a.Line - b.Line
+		}
+		return a.Column - b.Column
+	})
+	return root.diagnostics
+}
+
+func (this *checker) hasErrors() bool {
+	return slices.ContainsFunc(this.diagnostics, func(diagnostic Diagnostic) bool {
+		return diagnostic.Severity == SEVERITY_ERROR
+	})
+}
+
+// Whether some of `diagnostics` are errors
+func HasErrors(diagnostics []Diagnostic) bool {
+	return (&checker{diagnostics: diagnostics}).hasErrors()
+}
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"maps"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"slices"
 	"strconv"
@@ -16,6 +19,49 @@ import (
 type Config struct {
 	Tasks  []Task
 	LogDir string
+	Socket string
+	Http   string
+	// Whether the configuration is reloaded when its files change
+	Watch         bool
+	Notifications []Webhook
+	Acl           []AclRule
+	// Globs of the files whose tasks are added to the configuration
+	Include []string
+	// Default values of the properties of the tasks
+	Defaults map[string]any
+
+	warnings []string
+}
+
+// Get what could not be imported from the supervisord files of the
+// configuration, and was changed or ignored
+func (this *Config) Warnings() []string {
+	return this.warnings
+}
+
+// An URL notified with a POST request when one of `Events` happens
+type Webhook struct {
+	Url     string
+	Events  []string
+	Retries uint
+	Backoff Duration
+}
+
+// Role given to the clients of the UNIX sockets whose user id is `Uid`, or
+// belonging to the group `Gid`
+type AclRule struct {
+	Uid  *uint32
+	Gid  *uint32
+	Role string
+}
+
+func (this AclRule) String() string {
+	return fmt.Sprintf(
+		"{Uid:%s Gid:%s Role:%s}",
+		utils.PointerFormat(this.Uid),
+		utils.PointerFormat(this.Gid),
+		this.Role,
+	)
 }
 
 type Task struct {
@@ -27,14 +73,21 @@ type Task struct {
 	Restart            string
 	RestartAttempts    uint
 	ExpectedExitStatus int
-	StartTime          uint
-	StopTime           uint
+	StartTime          Duration
+	StopTime           Duration
 	StopSignal         string
 	Stdout             string
 	Stderr             string
+	StdoutLogFile      string
+	StderrLogFile      string
 	Environment        map[string]string
+	EnvironmentFiles   []string
 	WorkingDirectory   string
 	Permissions        *uint
+	Events             []string
+
+	// Keys of the inline environment whose values are templates
+	templatedEnvironment []string
 }
 
 func (this *Config) String() string {
@@ -42,6 +95,13 @@ func (this *Config) String() string {
 		"{\n"+
 			"  Tasks: %s\n"+
 			"  LogDir: %s\n"+
+			"  Socket: %s\n"+
+			"  Http: %s\n"+
+			"  Watch: %t\n"+
+			"  Notifications: %+v\n"+
+			"  Acl: %s\n"+
+			"  Include: %s\n"+
+			"  Defaults: %v\n"+
 			"}",
 		"[\n    "+
 			strings.Join(utils.Transform(this.Tasks, func(i int, task *Task) string {
@@ -60,9 +120,13 @@ func (this *Config) String() string {
 						"      StopSignal: %s\n"+
 						"      Stdout: %s\n"+
 						"      Stderr: %s\n"+
+						"      StdoutLogFile: %s\n"+
+						"      StderrLogFile: %s\n"+
 						"      Environment: %+v\n"+
+						"      EnvironmentFiles: %s\n"+
 						"      WorkingDirectory: %s\n"+
 						"      Permissions: %s\n"+
+						"      Events: %s\n"+
 						"    }",
 					utils.PointerFormat(task.Name),
 					utils.PointerFormat(task.Command),
@@ -77,13 +141,24 @@ func (this *Config) String() string {
 					task.StopSignal,
 					task.Stdout,
 					task.Stderr,
+					task.StdoutLogFile,
+					task.StderrLogFile,
 					task.Environment,
+					task.EnvironmentFiles,
 					task.WorkingDirectory,
 					utils.PointerFormat(task.Permissions),
+					task.Events,
 				)
 			}), "\n    ")+
 			"\n  ]",
 		this.LogDir,
+		this.Socket,
+		this.Http,
+		this.Watch,
+		this.Notifications,
+		this.Acl,
+		this.Include,
+		this.Defaults,
 	)
 }
 
@@ -103,9 +178,13 @@ func (this *Task) String() string {
 			"  StopSignal: %s\n"+
 			"  Stdout: %s\n"+
 			"  Stderr: %s\n"+
+			"  StdoutLogFile: %s\n"+
+			"  StderrLogFile: %s\n"+
 			"  Environment: %+v\n"+
+			"  EnvironmentFiles: %s\n"+
 			"  WorkingDirectory: %s\n"+
 			"  Permissions: %s\n"+
+			"  Events: %s\n"+
 			"}",
 		utils.PointerFormat(this.Name),
 		utils.PointerFormat(this.Command),
@@ -120,9 +199,13 @@ func (this *Task) String() string {
 		this.StopSignal,
 		this.Stdout,
 		this.Stderr,
+		this.StdoutLogFile,
+		this.StderrLogFile,
 		this.Environment,
+		this.EnvironmentFiles,
 		this.WorkingDirectory,
 		utils.PointerFormat(this.Permissions),
+		this.Events,
 	)
 }
 
@@ -155,6 +238,34 @@ func (this TaskInvalidPropertyError) Error() string {
 	)
 }
 
+type WebhookMissingPropertyError struct {
+	TaskPropertyError
+}
+
+func (this WebhookMissingPropertyError) Error() string {
+	return fmt.Sprintf("Missing required property for webhook: %s", this.property)
+}
+
+type AclRuleMissingPropertyError struct {
+	TaskPropertyError
+}
+
+func (this AclRuleMissingPropertyError) Error() string {
+	return fmt.Sprintf("Missing required property for ACL rule: %s", this.property)
+}
+
+func newAclRuleMissingPropertyError(property string) AclRuleMissingPropertyError {
+	return AclRuleMissingPropertyError{
+		TaskPropertyError{property},
+	}
+}
+
+func newWebhookMissingPropertyError(property string) WebhookMissingPropertyError {
+	return WebhookMissingPropertyError{
+		TaskPropertyError{property},
+	}
+}
+
 func newTaskMissingPropertyError(property string) TaskMissingPropertyError {
 	return TaskMissingPropertyError{
 		TaskPropertyError{property},
@@ -178,10 +289,19 @@ func newTaskEnumPropertyError(property, value string, enum []string) TaskEnumPro
 	}
 }
 
-func (this *Task) UnmarshalJSON(data []byte) error {
-	type LocalTask Task
+var (
+	NAME_PATTERN          = `^[a-zA-Z0-9_-]+$`
+	RESTART_VALUES        = []string{"always", "never", "on-failure", "unless-stopped"}
+	STOP_SIGNAL_VALUES    = []string{"SIGINT", "SIGQUIT", "SIGTERM", "SIGUSR1", "SIGUSR2", "SIGSTOP", "SIGTSTP"}
+	STDIO_VALUES          = []string{"ignore", "redirect"}
+	TASK_EVENTS_VALUES    = []string{"process", "reload", "log"}
+	WEBHOOK_EVENTS_VALUES = []string{"unexpected-exit", "retries-exhausted", "failed-to-start", "reload-failed"}
+	ROLE_VALUES           = []string{"read-only", "operator"}
+)
 
-	task := LocalTask{
+// Get a task with the default values of its properties
+func newTask() Task {
+	return Task{
 		Name:               nil,
 		Command:            nil,
 		Arguments:          []string{},
@@ -195,67 +315,273 @@ func (this *Task) UnmarshalJSON(data []byte) error {
 		StopSignal:         "SIGTERM",
 		Stdout:             "redirect",
 		Stderr:             "redirect",
+		StdoutLogFile:      "",
+		StderrLogFile:      "",
 		Environment:        map[string]string{},
+		EnvironmentFiles:   []string{},
 		WorkingDirectory:   ".",
 		Permissions:        nil,
+		Events:             []string{},
 	}
+}
+
+// Get a webhook with the default values of its properties
+func newWebhook() Webhook {
+	return Webhook{
+		Url:     "",
+		Events:  slices.Clone(WEBHOOK_EVENTS_VALUES),
+		Retries: 3,
+		Backoff: 1000,
+	}
+}
+
+func (this *Task) UnmarshalJSON(data []byte) error {
+	if errs := this.decode(data); len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Decode a task over its default values and validate it, getting every
+// problem found instead of only the first one
+func (this *Task) decode(data []byte) []error {
+	type LocalTask Task
+
+	task := LocalTask(newTask())
+	errs := []error{}
 
 	if err := json.Unmarshal(data, &task); err != nil {
-		return err
+		var typeErr *json.UnmarshalTypeError
+		var durationErr DurationError
+		if !errors.As(err, &typeErr) && !errors.As(err, &durationErr) {
+			return []error{err}
+		}
+		// Only the first type error is returned, and an invalid duration
+		// stops the decoding: the properties are decoded one by one to get
+		// the others
+		if typeErr != nil {
+			errs = append(errs, err)
+		}
+		task = LocalTask(newTask())
+		for _, err := range decodeProperties(data, &task) {
+			if len(errs) == 0 || err.Error() != errs[0].Error() {
+				errs = append(errs, err)
+			}
+		}
 	}
 
-	restartValues := []string{"always", "never", "on-failure", "unless-stopped"}
-	stopSignalValues := []string{"SIGINT", "SIGQUIT", "SIGTERM", "SIGUSR1", "SIGUSR2", "SIGSTOP", "SIGTSTP"}
-	stdioValues := []string{"ignore", "redirect"}
+	errs = append(errs, checkPropertyNames(data, reflect.TypeFor[Task]())...)
 
-	switch {
-	case task.Name == nil:
-		return newTaskMissingPropertyError("name")
+	if task.Name == nil {
+		errs = append(errs, newTaskMissingPropertyError("name"))
+	} else if regexp.MustCompile(NAME_PATTERN).MatchString(*task.Name) == false {
+		errs = append(errs, newTaskInvalidPropertyError("name", *task.Name, "must match the pattern "+NAME_PATTERN))
+	}
 
-	case regexp.MustCompile(`^[a-zA-Z0-9_-]+$`).MatchString(*task.Name) == false:
-		return newTaskInvalidPropertyError("name", *task.Name, "must match the pattern ^[a-zA-Z0-9_-]+$")
+	if task.Command == nil {
+		errs = append(errs, newTaskMissingPropertyError("command"))
+	}
 
-	case task.Command == nil:
-		return newTaskMissingPropertyError("command")
+	if task.Instances == 0 {
+		errs = append(errs, newTaskInvalidPropertyError("instances", "0", "must be 1 or greater"))
+	}
 
-	case task.Instances == 0:
-		return newTaskInvalidPropertyError("instances", "0", "must be 1 or greater")
+	if !slices.Contains(RESTART_VALUES, task.Restart) {
+		errs = append(errs, newTaskEnumPropertyError("restart", task.Restart, RESTART_VALUES))
+	}
 
-	case !slices.Contains(restartValues, task.Restart):
-		return newTaskEnumPropertyError("restart", task.Restart, restartValues)
+	if !slices.Contains(STOP_SIGNAL_VALUES, task.StopSignal) {
+		errs = append(errs, newTaskEnumPropertyError("stopSignal", task.StopSignal, STOP_SIGNAL_VALUES))
+	}
 
-	case !slices.Contains(stopSignalValues, task.StopSignal):
-		return newTaskEnumPropertyError("stopSignal", task.StopSignal, stopSignalValues)
+	if !slices.Contains(STDIO_VALUES, task.Stdout) {
+		errs = append(errs, newTaskEnumPropertyError("stdout", task.Stdout, STDIO_VALUES))
+	}
 
-	case !slices.Contains(stdioValues, task.Stdout):
-		return newTaskEnumPropertyError("stdout", task.Stdout, stdioValues)
+	if !slices.Contains(STDIO_VALUES, task.Stderr) {
+		errs = append(errs, newTaskEnumPropertyError("stderr", task.Stderr, STDIO_VALUES))
+	}
 
-	case !slices.Contains(stdioValues, task.Stderr):
-		return newTaskEnumPropertyError("stderr", task.Stderr, stdioValues)
+	if task.Permissions != nil && *task.Permissions > 777 {
+		errs = append(errs, newTaskInvalidPropertyError("permissions", fmt.Sprint(*task.Permissions), "umask value cannot be greater than 777"))
+	}
 
-	case task.Permissions != nil && *task.Permissions > 777:
-		return newTaskInvalidPropertyError("permissions", fmt.Sprint(*task.Permissions), "umask value cannot be greater than 777")
+	for _, event := range task.Events {
+		if !slices.Contains(TASK_EVENTS_VALUES, event) {
+			errs = append(errs, newTaskEnumPropertyError("events", event, TASK_EVENTS_VALUES))
+		}
 	}
 
 	if task.Permissions == nil {
 		task.Permissions = utils.New(uint(utils.GetUmask()))
 	} else if umask, err := strconv.ParseUint(fmt.Sprint(*task.Permissions), 8, 0); err != nil {
-		return newTaskInvalidPropertyError("permissions", fmt.Sprint(*task.Permissions), "umask value must be an octal value")
+		errs = append(errs, newTaskInvalidPropertyError("permissions", fmt.Sprint(*task.Permissions), "umask value must be an octal value"))
 	} else {
 		*task.Permissions = uint(umask)
 	}
 
-	if fileInfo, err := os.Stat(task.WorkingDirectory); err != nil {
-		return errors.New(fmt.Sprintf("Failed to get information on the current working directory (%s)", err))
-	} else if !fileInfo.IsDir() {
-		return newTaskInvalidPropertyError("workingDirectory", task.WorkingDirectory, "not a directory")
+	// Templated working directories depend on the instance, they are
+	// checked when the processes start
+	if !isTemplate(task.WorkingDirectory) {
+		if fileInfo, err := os.Stat(task.WorkingDirectory); err != nil {
+			errs = append(errs, newTaskInvalidPropertyError("workingDirectory", task.WorkingDirectory, fmt.Sprintf("failed to get information on the working directory (%s)", err)))
+		} else if !fileInfo.IsDir() {
+			errs = append(errs, newTaskInvalidPropertyError("workingDirectory", task.WorkingDirectory, "not a directory"))
+		}
 	}
 
+	// The inline environment overrides the environment files
+	environment, err := loadEnvironmentFiles(task.EnvironmentFiles)
+	if err != nil {
+		errs = append(errs, err)
+		environment = map[string]string{}
+	}
+	task.templatedEnvironment = []string{}
 	for k, v := range maps.All(task.Environment) {
-		task.Environment[k] = os.ExpandEnv(v)
+		environment[k] = os.ExpandEnv(v)
+		if isTemplate(v) {
+			task.templatedEnvironment = append(task.templatedEnvironment, k)
+		}
 	}
+	task.Environment = environment
 
 	*this = Task(task)
 
+	// Render the templates once, for their errors to be found now
+	if this.Name != nil && this.Command != nil {
+		if _, err := this.Render(TemplateData{TaskName: *this.Name, InstanceCount: this.Instances}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// Decode the properties of the object `data` one by one into `value`,
+// getting the error of each invalid property, named after it for the
+// invalid durations
+func decodeProperties(data []byte, value any) []error {
+	errs := []error{}
+	properties := map[string]json.RawMessage{}
+	json.Unmarshal(data, &properties)
+	for _, key := range slices.Sorted(maps.Keys(properties)) {
+		property, _ := json.Marshal(map[string]json.RawMessage{key: properties[key]})
+		var durationErr DurationError
+		if err := json.Unmarshal(property, value); errors.As(err, &durationErr) {
+			errs = append(errs, newTaskInvalidPropertyError(key, durationErr.value, DURATION_INFO))
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (this *Webhook) UnmarshalJSON(data []byte) error {
+	type LocalWebhook Webhook
+
+	webhook := LocalWebhook(newWebhook())
+
+	if err := json.Unmarshal(data, &webhook); err != nil {
+		var durationErr DurationError
+		if errors.As(err, &durationErr) {
+			return decodeProperties(data, &webhook)[0]
+		}
+		return err
+	}
+	if errs := checkPropertyNames(data, reflect.TypeFor[Webhook]()); len(errs) != 0 {
+		return errs[0]
+	}
+
+	if len(webhook.Url) == 0 {
+		return newWebhookMissingPropertyError("url")
+	}
+	if url, err := url.Parse(webhook.Url); err != nil || (url.Scheme != "http" && url.Scheme != "https") || len(url.Host) == 0 {
+		return newTaskInvalidPropertyError("url", webhook.Url, "must be an http or https URL")
+	}
+	for _, event := range webhook.Events {
+		if !slices.Contains(WEBHOOK_EVENTS_VALUES, event) {
+			return newTaskEnumPropertyError("events", event, WEBHOOK_EVENTS_VALUES)
+		}
+	}
+
+	*this = Webhook(webhook)
+
+	return nil
+}
+
+func (this *AclRule) UnmarshalJSON(data []byte) error {
+	type LocalAclRule AclRule
+
+	rule := LocalAclRule{
+		Uid:  nil,
+		Gid:  nil,
+		Role: "",
+	}
+
+	if err := json.Unmarshal(data, &rule); err != nil {
+		return err
+	}
+	if errs := checkPropertyNames(data, reflect.TypeFor[AclRule]()); len(errs) != 0 {
+		return errs[0]
+	}
+
+	switch {
+	case rule.Uid == nil && rule.Gid == nil:
+		return newAclRuleMissingPropertyError("uid or gid")
+
+	case len(rule.Role) == 0:
+		return newAclRuleMissingPropertyError("role")
+
+	case !slices.Contains(ROLE_VALUES, rule.Role):
+		return newTaskEnumPropertyError("role", rule.Role, ROLE_VALUES)
+	}
+
+	*this = AclRule(rule)
+
 	return nil
 }
+
+func isExecutable(path string) error {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		return err
+	case info.IsDir():
+		return errors.New("is a directory")
+	case info.Mode()&0o111 == 0:
+		return errors.New("permission denied")
+	}
+	return nil
+}
+
+// Find the executable of the task with the PATH of its environment, or the
+// one of taskmaster if it has none. Commands containing a slash are
+// relative to the working directory.
+func (this *Task) LookPath() (string, error) {
+	command := *this.Command
+	if strings.Contains(command, "/") {
+		path := command
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(this.WorkingDirectory, path)
+		}
+		if err := isExecutable(path); err != nil {
+			return "", fmt.Errorf("%s cannot be executed (%s)", command, err)
+		}
+		return command, nil
+	}
+
+	searchPath, ok := this.Environment["PATH"]
+	if !ok {
+		searchPath = os.Getenv("PATH")
+	}
+	for _, directory := range filepath.SplitList(searchPath) {
+		// Relative directories are relative to the working directory too
+		if !filepath.IsAbs(directory) {
+			directory = filepath.Join(this.WorkingDirectory, directory)
+		}
+		if path := filepath.Join(directory, command); isExecutable(path) == nil {
+			return filepath.Abs(path)
+		}
+	}
+	return "", fmt.Errorf("%s was not found in PATH (%s)", command, searchPath)
+}
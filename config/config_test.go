@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"taskmaster/utils"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -31,12 +35,23 @@ func TestParseValidFull(t *testing.T) {
 			"      StopSignal: SIGTERM\n"+
 			"      Stdout: redirect\n"+
 			"      Stderr: ignore\n"+
+			"      StdoutLogFile: \n"+
+			"      StderrLogFile: \n"+
 			"      Environment: map[WELCOME:Hello world!]\n"+
+			"      EnvironmentFiles: []\n"+
 			"      WorkingDirectory: /tmp\n"+
 			"      Permissions: "+fmt.Sprint(utils.Must(strconv.ParseUint("777", 8, 0)))+"\n"+
+			"      Events: []\n"+
 			"    }\n"+
 			"  ]\n"+
 			"  LogDir: /tmp/taskmaster-logs\n"+
+			"  Socket: /tmp/taskmaster.sock\n"+
+			"  Http: \n"+
+			"  Watch: true\n"+
+			"  Notifications: []\n"+
+			"  Acl: []\n"+
+			"  Include: []\n"+
+			"  Defaults: map[]\n"+
 			"}",
 		config.String(),
 	)
@@ -64,12 +79,23 @@ func TestParseValidPartial(t *testing.T) {
 			"      StopSignal: SIGTERM\n"+
 			"      Stdout: redirect\n"+
 			"      Stderr: redirect\n"+
+			"      StdoutLogFile: \n"+
+			"      StderrLogFile: \n"+
 			"      Environment: map[]\n"+
+			"      EnvironmentFiles: []\n"+
 			"      WorkingDirectory: .\n"+
 			"      Permissions: "+fmt.Sprint(utils.GetUmask())+"\n"+
+			"      Events: []\n"+
 			"    }\n"+
 			"  ]\n"+
 			"  LogDir: /tmp/taskmaster-logs\n"+
+			"  Socket: /tmp/taskmaster.sock\n"+
+			"  Http: \n"+
+			"  Watch: false\n"+
+			"  Notifications: []\n"+
+			"  Acl: []\n"+
+			"  Include: []\n"+
+			"  Defaults: map[]\n"+
 			"}",
 		config.String(),
 	)
@@ -114,7 +140,7 @@ func TestParseInvalidNoJson(t *testing.T) {
 	_, err := Parse("testdata/invalid_no_json")
 
 	require.NotNil(t, err)
-	require.Equal(t, "Error while parsing configuration file: Invalid config file format (expected a json file)", err.Error())
+	require.Equal(t, "Error while parsing configuration file: Invalid config file format (expected a json, yaml, yml, toml, conf or ini file)", err.Error())
 }
 
 func TestParseInvalidType1(t *testing.T) {
@@ -151,3 +177,627 @@ func TestParseInvalidType5(t *testing.T) {
 	require.NotNil(t, err)
 	require.Equal(t, "Error while parsing configuration file: json: cannot unmarshal object into Go struct field Config.Tasks of type string", err.Error())
 }
+
+func TestParseYAMLValidFull(t *testing.T) {
+	jsonConfig, err := Parse("testdata/valid_full.json")
+	require.Nil(t, err)
+	yamlConfig, err := Parse("testdata/valid_full.yaml")
+
+	require.Nil(t, err)
+	require.Equal(t, jsonConfig.String(), yamlConfig.String())
+}
+
+func TestParseYAMLInvalidEnum(t *testing.T) {
+	_, err := Parse("testdata/invalid_enum.yml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 8: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')", err.Error())
+}
+
+func TestParseYAMLInvalidType(t *testing.T) {
+	_, err := Parse("testdata/invalid_type.yaml")
+
+	require.NotNil(t, err)
+	require.Regexp(t, "^Error while parsing configuration file: line 4: json: cannot unmarshal array into Go struct field .*instances of type uint$", err.Error())
+}
+
+func TestParseYAMLMissingProperty(t *testing.T) {
+	_, err := Parse("testdata/invalid_missing.yaml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 4: Missing required property for task: command", err.Error())
+}
+
+func TestParseYAMLAliasedTasks(t *testing.T) {
+	_, err := Parse("testdata/invalid_alias.yaml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 4: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')", err.Error())
+
+	// Merge keys are not followed, the error has no line
+	_, err = Parse("testdata/invalid_merge.yaml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')", err.Error())
+}
+
+func TestParseTOMLValidFull(t *testing.T) {
+	jsonConfig, err := Parse("testdata/valid_full.json")
+	require.Nil(t, err)
+	tomlConfig, err := Parse("testdata/valid_full.toml")
+
+	require.Nil(t, err)
+	require.Equal(t, jsonConfig.String(), tomlConfig.String())
+}
+
+func TestParseTOMLInvalidEnum(t *testing.T) {
+	_, err := Parse("testdata/invalid_enum.toml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 8: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')", err.Error())
+}
+
+func TestParseTOMLInvalidType(t *testing.T) {
+	_, err := Parse("testdata/invalid_type.toml")
+
+	require.NotNil(t, err)
+	require.Regexp(t, "^Error while parsing configuration file: line 1: json: cannot unmarshal number into Go struct field Config.logDir of type string$", err.Error())
+}
+
+func TestImportSupervisord(t *testing.T) {
+	imported, warnings, err := ImportSupervisord("testdata/supervisord.conf")
+
+	require.Nil(t, err)
+	require.Equal(t, "/tmp/supervisor.sock", imported.Socket)
+	require.Equal(
+		t,
+		[]ImportedTask{
+			{
+				Name:               "web-server",
+				Command:            "bash",
+				Arguments:          []string{"-c", "echo $WELCOME"},
+				StartAtLaunch:      false,
+				Instances:          2,
+				Restart:            "always",
+				RestartAttempts:    1,
+				ExpectedExitStatus: 0,
+				StartTime:          5000,
+				StopTime:           3000,
+				StopSignal:         "SIGQUIT",
+				Stdout:             "redirect",
+				Stderr:             "ignore",
+				StdoutLogFile:      "/var/log/web.log",
+				Environment:        map[string]string{"WELCOME": "Hello, world!", "PROGRAM": "web.server"},
+				WorkingDirectory:   "/tmp",
+				Permissions:        utils.New(uint(22)),
+			},
+			{
+				Name:               "worker",
+				Command:            "sleep",
+				Arguments:          []string{`10{{printf "%02d" .Instance}}`},
+				StartAtLaunch:      true,
+				Instances:          1,
+				Restart:            "on-failure",
+				RestartAttempts:    3,
+				ExpectedExitStatus: 0,
+				StartTime:          1000,
+				StopTime:           10000,
+				StopSignal:         "SIGTERM",
+				Stdout:             "redirect",
+				Stderr:             "redirect",
+				StdoutLogFile:      "/tmp/worker-%(process_num)x.log",
+				StderrLogFile:      `/tmp/worker-{{printf "%03d" .Instance}}.log`,
+			},
+		},
+		imported.Tasks,
+	)
+	require.Equal(
+		t,
+		[]string{
+			"line 11: program web.server was renamed web-server, task names must match ^[a-zA-Z0-9_-]+$",
+			"line 27: user is not supported, it was ignored",
+			"line 17: startretries=0 has no equivalent (0 restart attempts means no limit), 1 was used instead",
+			"line 18: only one exit code can be expected, 0 was used",
+			"line 31: /tmp/%(program_name)s-%(process_num)x.log contains a %( that is not a supported expression, it was left as is",
+			"line 34: [group:all] sections are not supported, it was ignored",
+		},
+		warnings,
+	)
+}
+
+func TestParseSupervisord(t *testing.T) {
+	config, err := Parse("testdata/supervisord.conf")
+
+	require.Nil(t, err)
+	require.Len(t, config.Tasks, 2)
+	require.Equal(t, "web-server", *config.Tasks[0].Name)
+	require.Equal(t, uint(0o22), *config.Tasks[0].Permissions)
+	require.Equal(t, "/tmp/supervisor.sock", config.Socket)
+	require.Len(t, config.Warnings(), 6)
+	require.Equal(t, "testdata/supervisord.conf: line 11: program web.server was renamed web-server, task names must match ^[a-zA-Z0-9_-]+$", config.Warnings()[0])
+
+	// Formatted expressions are rendered the way supervisord formats them
+	worker, err := config.Tasks[1].Render(NewTemplateData(config, &config.Tasks[1], 7))
+	require.Nil(t, err)
+	require.Equal(t, []string{"1007"}, worker.Arguments)
+	require.Equal(t, "/tmp/worker-007.log", worker.StderrLogFile)
+}
+
+func TestImportSupervisordMissingCommand(t *testing.T) {
+	_, _, err := ImportSupervisord("testdata/invalid_supervisord.ini")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Invalid supervisord configuration: line 1: missing command for program web", err.Error())
+}
+
+func TestParseInclude(t *testing.T) {
+	config, err := Parse("testdata/include.json")
+
+	require.Nil(t, err)
+	require.Equal(
+		t,
+		[]string{"root", "web", "worker"},
+		utils.Transform(config.Tasks, func(i int, task *Task) string { return *task.Name }),
+	)
+	require.Equal(t, uint(2), config.Tasks[2].Instances)
+}
+
+func TestParseIncludeDuplicate(t *testing.T) {
+	_, err := Parse("testdata/include_duplicate.json")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: Multiple tasks with the same name: web (in testdata/include_duplicate.json and testdata/include.d/web.json)", err.Error())
+}
+
+func TestParseIncludeInvalid(t *testing.T) {
+	_, err := Parse("testdata/include_invalid.json")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: testdata/invalid_enum.yml: line 8: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')", err.Error())
+}
+
+func TestParseDefaultsAndExtends(t *testing.T) {
+	config, err := Parse("testdata/defaults.yaml")
+
+	require.Nil(t, err)
+	base, child, grandchild := config.Tasks[0], config.Tasks[1], config.Tasks[2]
+
+	require.Equal(t, "always", base.Restart)
+	require.Equal(t, "SIGINT", base.StopSignal)
+	require.Equal(t, map[string]string{"LEVEL": "debug", "REGION": "eu"}, base.Environment)
+
+	require.Equal(t, "echo", *child.Command)
+	require.Equal(t, Duration(1000), child.StopTime)
+	require.Equal(t, map[string]string{"LEVEL": "debug", "REGION": "eu", "SERVICE": "child"}, child.Environment)
+
+	require.Equal(t, "grandchild", *grandchild.Name)
+	require.Equal(t, "echo", *grandchild.Command)
+	require.Equal(t, "never", grandchild.Restart)
+	require.Equal(t, child.Environment, grandchild.Environment)
+}
+
+func TestParseIncludeDefaults(t *testing.T) {
+	config, err := Parse("testdata/include_defaults.json")
+
+	require.Nil(t, err)
+	for _, task := range config.Tasks {
+		require.Equal(t, "never", task.Restart)
+	}
+}
+
+func TestParseInvalidExtendsCycle(t *testing.T) {
+	_, err := Parse("testdata/invalid_extends.yaml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 4: Invalid value for property extends: first (a task cannot extend itself)", err.Error())
+}
+
+func TestParseInvalidExtendsUnknown(t *testing.T) {
+	_, err := Parse("testdata/invalid_extends_unknown.json")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: Invalid value for property extends: missing (no task has this name)", err.Error())
+}
+
+func TestParseEnvironmentFiles(t *testing.T) {
+	config, err := Parse("testdata/environment_files.json")
+
+	require.Nil(t, err)
+	require.Equal(
+		t,
+		map[string]string{
+			"HOST":        "example.com",
+			"PORT":        "8080",
+			"URL":         "http://localhost:8080",
+			"GREETING":    "Hello, ${HOST}",
+			"LEVEL":       "debug",
+			"ESCAPED":     "costs $5\ttab",
+			"CERTIFICATE": "-----BEGIN-----\nlocalhost\n-----END-----",
+			"EMPTY":       "",
+		},
+		config.Tasks[0].Environment,
+	)
+}
+
+func TestParseInvalidEnvironmentFiles(t *testing.T) {
+	_, err := Parse("testdata/invalid_environment_files.json")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: Invalid value for property environmentFiles: testdata/invalid_env.env (line 2: expected KEY=value)", err.Error())
+}
+
+func TestRenderTemplates(t *testing.T) {
+	config, err := Parse("testdata/templates.json")
+	require.Nil(t, err)
+
+	task, err := config.Tasks[0].Render(NewTemplateData(config, &config.Tasks[0], 1))
+
+	require.Nil(t, err)
+	require.Equal(t, "/usr/bin/web", *task.Command)
+	require.Equal(t, []string{"--port", "8001", "--of", "2"}, task.Arguments)
+	require.Equal(t, map[string]string{"ID": "web-1", "SECRET": "{{not a template}}"}, task.Environment)
+	require.Equal(t, "/tmp/web", task.WorkingDirectory)
+	require.Equal(t, "/tmp/taskmaster-logs/web-1.log", task.StdoutLogFile)
+	require.Equal(t, "{{.TaskName}}-{{.Instance}}", config.Tasks[0].Environment["ID"])
+}
+
+func TestParseInvalidTemplate(t *testing.T) {
+	_, err := Parse("testdata/invalid_template.json")
+
+	require.NotNil(t, err)
+	require.Regexp(t, `^Error while parsing configuration file: Invalid value for property arguments: \{\{\.Port\}\} \(.*can't evaluate field Port.*\)$`, err.Error())
+}
+
+func TestCheckErrors(t *testing.T) {
+	diagnostics := Check("testdata/check_invalid.yaml")
+
+	require.True(t, HasErrors(diagnostics))
+	require.Equal(
+		t,
+		[]string{
+			"testdata/check_invalid.yaml:1:1: error: logDir: json: cannot unmarshal number into Go struct field Config.logDir of type string",
+			"testdata/check_invalid.yaml:4:5: error: notifications[1].url: Invalid value for property url: ftp://localhost (must be an http or https URL)",
+			"testdata/check_invalid.yaml:8:5: error: task web: tasks[0].restart: Invalid value for property restart: sometimes (must be one of 'always', 'never', 'on-failure', 'unless-stopped')",
+			"testdata/check_invalid.yaml:9:5: error: task web: tasks[0].instances: Invalid value for property instances: 0 (must be 1 or greater)",
+			"testdata/check_invalid.yaml:12:5: error: task worker: tasks[1].extends: Invalid value for property extends: nobody (no task has this name)",
+		},
+		utils.Transform(diagnostics[:5], func(i int, diagnostic *Diagnostic) string { return diagnostic.String() }),
+	)
+	require.Len(t, diagnostics, 6)
+	require.Equal(t, "arguments", diagnostics[5].Task)
+	require.Equal(t, "tasks[2].arguments[0]", diagnostics[5].Path)
+	require.Equal(t, Position{15, 17}, Position{diagnostics[5].Line, diagnostics[5].Column})
+}
+
+func TestCheckWarnings(t *testing.T) {
+	diagnostics := Check("testdata/check_warnings.json")
+
+	require.False(t, HasErrors(diagnostics))
+	require.Equal(
+		t,
+		[]string{
+			"testdata/check_warnings.json:6:7: warning: task missing: tasks[0].command: nosuchcommand was not found in PATH (/nonexistent)",
+			"testdata/check_warnings.json:13:7: warning: task never: tasks[1].restartAttempts: restartAttempts has no effect when restart is 'never'",
+			"testdata/check_warnings.json:16:3: warning: include[1]: no file matches testdata/nothing.d/*.json",
+		},
+		utils.Transform(diagnostics, func(i int, diagnostic *Diagnostic) string { return diagnostic.String() }),
+	)
+}
+
+func TestCheckIncludeDuplicate(t *testing.T) {
+	diagnostics := Check("testdata/include_duplicate.json")
+
+	require.Equal(
+		t,
+		[]Diagnostic{{
+			Severity: SEVERITY_ERROR,
+			File:     "testdata/include.d/web.json",
+			Line:     5,
+			Column:   7,
+			Task:     "web",
+			Path:     "tasks[0].name",
+			Message:  "Multiple tasks with the same name: web (in testdata/include_duplicate.json and testdata/include.d/web.json)",
+		}},
+		diagnostics,
+	)
+}
+
+func TestSchemaIsGenerated(t *testing.T) {
+	schema, err := JSONSchema()
+	require.Nil(t, err)
+
+	committed, err := os.ReadFile("../tmconfig.schema.json")
+	require.Nil(t, err)
+	require.Equal(t, string(committed), string(schema), "tmconfig.schema.json is out of date, run go generate")
+}
+
+func TestParseUnknownProperty(t *testing.T) {
+	_, err := Parse("testdata/invalid_unknown_property.yaml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 6: Unknown property stopsignal (did you mean stopSignal?)", err.Error())
+}
+
+func TestParseUnknownTopLevelProperty(t *testing.T) {
+	_, err := Parse("testdata/invalid_unknown_property.toml")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: line 1: Unknown property logdir (did you mean logDir?)", err.Error())
+}
+
+func TestParseUnknownDefaultsProperty(t *testing.T) {
+	_, err := Parse("testdata/invalid_unknown_defaults.json")
+
+	require.NotNil(t, err)
+	require.Equal(t, "Error while parsing configuration file: Unknown property restartAtempts (did you mean restartAttempts?)", err.Error())
+}
+
+func TestCheckUnknownProperties(t *testing.T) {
+	diagnostics := Check("testdata/check_unknown_property.json")
+
+	require.Equal(
+		t,
+		[]string{
+			"testdata/check_unknown_property.json:3:3: error: sockets: Unknown property sockets (did you mean socket?)",
+			"testdata/check_unknown_property.json:4:50: error: notifications[0].retry: Unknown property retry",
+			"testdata/check_unknown_property.json:9:7: error: task web: tasks[0].Instances: Unknown property Instances (did you mean instances?)",
+			"testdata/check_unknown_property.json:10:7: error: task web: tasks[0].colour: Unknown property colour",
+		},
+		utils.Transform(diagnostics, func(i int, diagnostic *Diagnostic) string { return diagnostic.String() }),
+	)
+}
+
+func TestWatch(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "tmconfig.json")
+	write := func(name, content string) {
+		require.Nil(t, os.WriteFile(filepath.Join(directory, name), []byte(content), 0o644))
+	}
+	config := fmt.Sprintf(`{"logDir": "%s", "include": ["conf.d/*.json"], "tasks": [{"name": "web", "command": "/bin/sleep"}]}`, directory)
+	write("tmconfig.json", config)
+	require.Nil(t, os.Mkdir(filepath.Join(directory, "conf.d"), 0o755))
+
+	manager, err := NewManager(path)
+	require.Nil(t, err)
+	changes := make(chan struct{}, 16)
+	watcher, err := Watch(path, manager, 50*time.Millisecond, func() { changes <- struct{}{} })
+	require.Nil(t, err)
+	defer watcher.Close()
+
+	// Only one reload after several changes in a row
+	write("tmconfig.json", config)
+	write("tmconfig.json", config)
+	require.Eventually(t, func() bool { return len(changes) == 1 }, time.Second, 10*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, changes, 1)
+	<-changes
+
+	write("notes.txt", "not a configuration file")
+	write("conf.d/notes.txt", "not included")
+	time.Sleep(200 * time.Millisecond)
+	require.Len(t, changes, 0)
+
+	write("conf.d/worker.json", `{"tasks": []}`)
+	require.Eventually(t, func() bool { return len(changes) == 1 }, time.Second, 10*time.Millisecond)
+	<-changes
+
+	// The environment files are watched once the configuration uses them
+	require.Nil(t, os.Mkdir(filepath.Join(directory, "env"), 0o755))
+	write("env/web.env", "PORT=8080")
+	write("tmconfig.json", fmt.Sprintf(`{"logDir": "%s", "tasks": [{"name": "web", "command": "/bin/sleep", "environmentFiles": ["env/web.env"]}]}`, directory))
+	require.Nil(t, manager.Load())
+	require.Eventually(t, func() bool { return len(changes) == 1 }, time.Second, 10*time.Millisecond)
+	<-changes
+	write("env/web.env", "PORT=8081")
+	require.Eventually(t, func() bool { return len(changes) == 1 }, time.Second, 10*time.Millisecond)
+}
+
+// Parse a configuration with `count` copies of the task of valid_full.json
+func parseTasks(t *testing.T, count int) *Config {
+	config, err := Parse("testdata/valid_full.json")
+	require.Nil(t, err)
+	for i := range count {
+		task := config.Tasks[0]
+		task.Name = utils.New(fmt.Sprintf("task%d", i))
+		task.Arguments = slices.Clone(task.Arguments)
+		config.Tasks = append(config.Tasks, task)
+	}
+	config.Tasks = config.Tasks[1:]
+	return config
+}
+
+func TestPlanReload(t *testing.T) {
+	current, next := parseTasks(t, 4), parseTasks(t, 4)
+
+	next.Tasks[0].Instances++
+	next.Tasks[0].RestartAttempts++
+	next.Tasks[1].Restart = "never"
+	next.Tasks[2].Arguments = append(next.Tasks[2].Arguments, "--verbose")
+	next.Tasks[2].Instances++
+
+	instances := current.Tasks[0].Instances
+	require.Equal(
+		t,
+		[]TaskChange{
+			{"task0", TASK_SCALED, []string{"instances", "restartAttempts"}, instances, instances + 1},
+			{"task1", TASK_UPDATED, []string{"restart"}, instances, instances},
+			{"task2", TASK_RESTARTED, []string{"arguments", "instances"}, instances, instances + 1},
+			{"task3", TASK_UNCHANGED, []string{}, instances, instances},
+		},
+		PlanReload(current, next).Tasks,
+	)
+}
+
+func TestLookPath(t *testing.T) {
+	directory := t.TempDir()
+	require.Nil(t, os.Mkdir(filepath.Join(directory, "bin"), 0o755))
+	require.Nil(t, os.WriteFile(filepath.Join(directory, "bin", "tool"), []byte("#!/bin/sh\n"), 0o755))
+	require.Nil(t, os.WriteFile(filepath.Join(directory, "bin", "notes"), []byte("not a program"), 0o644))
+	lookPath := func(command string, environment map[string]string) (string, error) {
+		task := Task{Command: utils.New(command), WorkingDirectory: directory, Environment: environment}
+		return task.LookPath()
+	}
+
+	// Relative to the working directory, and kept as is
+	path, err := lookPath("bin/tool", map[string]string{})
+	require.Nil(t, err)
+	require.Equal(t, "bin/tool", path)
+
+	// In the PATH of the task, whose relative directories are relative to
+	// the working directory
+	path, err = lookPath("tool", map[string]string{"PATH": "/nonexistent:bin"})
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(directory, "bin", "tool"), path)
+
+	_, err = lookPath("tool", map[string]string{"PATH": "/nonexistent"})
+	require.NotNil(t, err)
+	require.Equal(t, "tool was not found in PATH (/nonexistent)", err.Error())
+
+	_, err = lookPath("bin/notes", map[string]string{})
+	require.NotNil(t, err)
+	require.Equal(t, "bin/notes cannot be executed (permission denied)", err.Error())
+
+	_, err = lookPath("notes", map[string]string{"PATH": "bin"})
+	require.NotNil(t, err)
+	require.Equal(t, "notes was not found in PATH (bin)", err.Error())
+}
+
+func TestPlanReloadInstanceCount(t *testing.T) {
+	current, next := parseTasks(t, 2), parseTasks(t, 2)
+	for _, config := range []*Config{current, next} {
+		config.Tasks[1].Arguments = append(config.Tasks[1].Arguments, "--of", "{{.InstanceCount}}")
+	}
+	next.Tasks[0].Instances++
+	next.Tasks[1].Instances++
+
+	plan := PlanReload(current, next)
+
+	require.Equal(t, TASK_SCALED, plan.Tasks[0].Action)
+	// Every process is given the new number of instances
+	require.Equal(t, TASK_RESTARTED, plan.Tasks[1].Action)
+	require.Equal(t, []string{"instances"}, plan.Tasks[1].Properties)
+}
+
+func TestPlanReloadLogDir(t *testing.T) {
+	current, next := parseTasks(t, 3), parseTasks(t, 2)
+	next.LogDir = t.TempDir()
+
+	plan := PlanReload(current, next)
+	require.Len(t, plan.Tasks, 3)
+	for _, change := range plan.Tasks[:2] {
+		require.Equal(t, TASK_RESTARTED, change.Action)
+		require.Equal(t, []string{"logDir"}, change.Properties)
+	}
+	require.Equal(t, TASK_REMOVED, plan.Tasks[2].Action)
+}
+
+func TestManagerPlanWithoutSideEffects(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "tmconfig.json")
+	write := func(logDir string) {
+		config := fmt.Sprintf(`{"logDir": "%s", "tasks": [{"name": "web", "command": "/bin/sleep"}]}`, logDir)
+		require.Nil(t, os.WriteFile(path, []byte(config), 0o644))
+	}
+	write(filepath.Join(directory, "logs"))
+	manager, err := NewManager(path)
+	require.Nil(t, err)
+	require.DirExists(t, filepath.Join(directory, "logs"))
+
+	write(filepath.Join(directory, "next-logs"))
+	plan, err := manager.Plan()
+	require.Nil(t, err)
+	require.Equal(t, TASK_RESTARTED, plan.Tasks[0].Action)
+	require.NoDirExists(t, filepath.Join(directory, "next-logs"))
+
+	require.Nil(t, manager.Load())
+	require.DirExists(t, filepath.Join(directory, "next-logs"))
+}
+
+func TestManagerConcurrentLoads(t *testing.T) {
+	directory := t.TempDir()
+	path := filepath.Join(directory, "tmconfig.json")
+	write := func(name string) {
+		config := fmt.Sprintf(`{"logDir": "%s", "tasks": [{"name": "%s", "command": "/bin/sleep"}]}`, directory, name)
+		require.Nil(t, os.WriteFile(path, []byte(config), 0o644))
+	}
+	write("first")
+	manager, err := NewManager(path)
+	require.Nil(t, err)
+	changes := make(chan [2]*Config, 2)
+	manager.Subscribe(func(old, new *Config) error {
+		time.Sleep(20 * time.Millisecond)
+		changes <- [2]*Config{old, new}
+		return nil
+	})
+	initial := manager.Get()
+
+	write("second")
+	loads := make(chan error)
+	for range 2 {
+		go func() { loads <- manager.Load() }()
+	}
+	require.Nil(t, <-loads)
+	require.Nil(t, <-loads)
+
+	// The second load starts from the configuration of the first one
+	first, second := <-changes, <-changes
+	require.Same(t, initial, first[0])
+	require.Same(t, first[1], second[0])
+	require.Same(t, second[1], manager.Get())
+	require.Equal(t, "second", *manager.Get().Tasks[0].Name)
+}
+
+func TestPlanReloadByName(t *testing.T) {
+	current, next := parseTasks(t, 3), parseTasks(t, 4)
+	next.Tasks = []Task{next.Tasks[3], next.Tasks[2], next.Tasks[0]}
+
+	instances := current.Tasks[0].Instances
+	require.Equal(
+		t,
+		[]TaskChange{
+			{"task3", TASK_ADDED, nil, 0, instances},
+			{"task2", TASK_UNCHANGED, []string{}, instances, instances},
+			{"task0", TASK_UNCHANGED, []string{}, instances, instances},
+			{"task1", TASK_REMOVED, nil, instances, 0},
+		},
+		PlanReload(current, next).Tasks,
+	)
+}
+
+func TestTaskChangeString(t *testing.T) {
+	current, next := parseTasks(t, 2), parseTasks(t, 2)
+	next.Tasks[0].Instances++
+	next.Tasks[0].StopTime++
+	next.Tasks[1].Command = utils.New("/bin/true")
+
+	instances := current.Tasks[0].Instances
+	plan := PlanReload(current, next)
+	require.Equal(t, fmt.Sprintf("task0: scaled from %d to %d instances, instances, stopTime changed", instances, instances+1), plan.Tasks[0].String())
+	require.Equal(t, "task1: restarted, command changed", plan.Tasks[1].String())
+	require.Equal(t, "worker: added, starting 2 instances", TaskChange{Name: "worker", Action: TASK_ADDED, NextInstances: 2}.String())
+}
+
+func TestParseDurations(t *testing.T) {
+	config, err := Parse("testdata/valid_durations.yaml")
+
+	require.Nil(t, err)
+	require.Equal(t, Duration(1500), config.Notifications[0].Backoff)
+	require.Equal(t, Duration(500), config.Tasks[0].StartTime)
+	require.Equal(t, Duration(120000), config.Tasks[0].StopTime)
+	require.Equal(t, Duration(250), config.Tasks[1].StartTime)
+	require.Equal(t, Duration(0), config.Tasks[1].StopTime)
+	require.Contains(t, config.Tasks[0].String(), "  StopTime: 120000\n")
+}
+
+func TestCheckInvalidDurations(t *testing.T) {
+	diagnostics := Check("testdata/check_invalid_duration.json")
+
+	require.Equal(
+		t,
+		[]string{
+			`testdata/check_invalid_duration.json:6:7: error: task web: tasks[0].startTime: Invalid value for property startTime: 10 parsecs (must be a number of milliseconds or a duration like "500ms", "10s" or "2m")`,
+			`testdata/check_invalid_duration.json:7:7: error: task web: tasks[0].stopTime: Invalid value for property stopTime: -2s (must be a number of milliseconds or a duration like "500ms", "10s" or "2m")`,
+		},
+		utils.Transform(diagnostics, func(i int, diagnostic *Diagnostic) string { return diagnostic.String() }),
+	)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var dotenvKey = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.]*$`)
+
+// Expand ${VAR} and $VAR with the variables of `environment`, or else with
+// the environment of taskmaster
+func expandDotenv(value string, environment map[string]string) string {
+	return os.Expand(value, func(name string) string {
+		if value, ok := environment[name]; ok {
+			return value
+		}
+		return os.Getenv(name)
+	})
+}
+
+// Read the end of a double-quoted value, starting after the opening quote,
+// expanding the variables that are not escaped. `rest` is what follows the
+// closing quote.
+func readDoubleQuoted(value string, environment map[string]string) (result, rest string, ok bool) {
+	builder := new(strings.Builder)
+	start := 0
+	flush := func(end int) {
+		builder.WriteString(expandDotenv(value[start:end], environment))
+	}
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			flush(i)
+			return builder.String(), value[i+1:], true
+		case '\\':
+			if i+1 == len(value) {
+				continue
+			}
+			flush(i)
+			i++
+			switch value[i] {
+			case 'n':
+				builder.WriteByte('\n')
+			case 't':
+				builder.WriteByte('\t')
+			default:
+				builder.WriteByte(value[i])
+			}
+			start = i + 1
+		}
+	}
+	return "", "", false
+}
+
+// Parse a dotenv file: KEY=value lines, optionally prefixed with "export",
+// with '#' comments. Values may be single-quoted (taken literally),
+// double-quoted (with escapes, and spanning several lines) or unquoted, and
+// ${VAR} is expanded in the last two, with the variables of `environment`
+// defined so far.
+func parseDotenv(data string, environment map[string]string) error {
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(lines[i])
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if exported, ok := strings.CutPrefix(line, "export"); ok && len(exported) != 0 && (exported[0] == ' ' || exported[0] == '\t') {
+			line = strings.TrimSpace(exported)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dotenvKey.MatchString(key) {
+			// The line is left out, it may hold a secret
+			return fmt.Errorf("line %d: expected KEY=value", number)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		rest := ""
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.IndexByte(value[1:], '\'')
+			if end == -1 {
+				return fmt.Errorf("line %d: unterminated single quote", number)
+			}
+			value, rest = value[1:end+1], value[end+2:]
+
+		case strings.HasPrefix(value, "\""):
+			quoted := value[1:]
+			for {
+				if result, after, ok := readDoubleQuoted(quoted, environment); ok {
+					value, rest = result, after
+					break
+				}
+				if i++; i == len(lines) {
+					return fmt.Errorf("line %d: unterminated double quote", number)
+				}
+				quoted += "\n" + lines[i]
+			}
+
+		default:
+			if comment := strings.Index(value, " #"); comment != -1 {
+				value = value[:comment]
+			}
+			value = expandDotenv(strings.TrimSpace(value), environment)
+		}
+
+		if rest = strings.TrimSpace(rest); len(rest) != 0 && rest[0] != '#' {
+			return fmt.Errorf("line %d: unexpected characters after the value of %s", number, key)
+		}
+		environment[key] = value
+	}
+	return nil
+}
+
+// Make the relative environment files of the defaults and of the tasks of a
+// configuration decoded from any format relative to `dir`, the directory of
+// the configuration file
+func resolveEnvironmentFiles(document any, dir string) {
+	objects := []any{}
+	if defaults, ok := lookup(document, "defaults"); ok {
+		objects = append(objects, defaults)
+	}
+	if tasks, ok := lookup(document, "tasks"); ok {
+		if tasks, ok := tasks.([]any); ok {
+			objects = append(objects, tasks...)
+		}
+	}
+	for _, object := range objects {
+		object, ok := object.(map[string]any)
+		if !ok {
+			continue
+		}
+		for key, value := range object {
+			if paths, ok := value.([]any); ok && strings.EqualFold(key, "environmentFiles") {
+				for i, path := range paths {
+					if path, ok := path.(string); ok && !filepath.IsAbs(path) {
+						paths[i] = filepath.Join(dir, path)
+					}
+				}
+			}
+		}
+	}
+}
+
+// Load the variables of the dotenv files at `paths` in order, later files
+// overriding earlier ones
+func loadEnvironmentFiles(paths []string) (map[string]string, error) {
+	environment := map[string]string{}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, newTaskInvalidPropertyError("environmentFiles", path, err.Error())
+		}
+		if err := parseDotenv(string(data), environment); err != nil {
+			return nil, newTaskInvalidPropertyError("environmentFiles", path, err.Error())
+		}
+	}
+	return environment, nil
+}
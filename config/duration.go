@@ -0,0 +1,52 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A duration in milliseconds, written in the configuration either as a
+// number of milliseconds or as a Go duration string like "500ms", "10s" or
+// "2m", rounded down to the millisecond
+type Duration uint
+
+const DURATION_PATTERN = `^(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h))+)$`
+
+const DURATION_INFO = `must be a number of milliseconds or a duration like "500ms", "10s" or "2m"`
+
+type DurationError struct {
+	value string
+}
+
+func (this DurationError) Error() string {
+	return fmt.Sprintf("Invalid duration: %s (%s)", this.value, DURATION_INFO)
+}
+
+func newDurationError(value string) DurationError {
+	return DurationError{value}
+}
+
+func (this *Duration) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		var milliseconds uint
+		if err := json.Unmarshal(data, &milliseconds); err != nil {
+			return newDurationError(string(data))
+		}
+		*this = Duration(milliseconds)
+		return nil
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil || duration < 0 {
+		return newDurationError(text)
+	}
+	*this = Duration(duration.Milliseconds())
+	return nil
+}
+
+// Get the duration as a time.Duration
+func (this Duration) Duration() time.Duration {
+	return time.Duration(this) * time.Millisecond
+}
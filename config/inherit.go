@@ -0,0 +1,174 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Properties of a task that are never inherited
+var UNINHERITED_PROPERTIES = []string{"name", "extends"}
+
+// Merge `override` over `base` into a new object, merging the objects they
+// both have instead of replacing them. Keys match the same way as
+// encoding/json does.
+func merge(base, override map[string]any) map[string]any {
+	result := make(map[string]any, len(base)+len(override))
+	for key, value := range base {
+		result[key] = value
+	}
+	for key, value := range override {
+		for baseKey, baseValue := range result {
+			if !strings.EqualFold(baseKey, key) {
+				continue
+			}
+			delete(result, baseKey)
+			if object, ok := value.(map[string]any); ok {
+				if baseObject, ok := baseValue.(map[string]any); ok {
+					value = merge(baseObject, object)
+				}
+			}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// Copy `object` without the properties in `properties`
+func without(object map[string]any, properties []string) map[string]any {
+	result := make(map[string]any, len(object))
+	for key, value := range object {
+		if !slices.ContainsFunc(properties, func(property string) bool { return strings.EqualFold(property, key) }) {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// Get the defaults of a configuration decoded from any format, layered on
+// top of `inherited`
+func resolveDefaults(document any, inherited map[string]any) (map[string]any, error) {
+	value, ok := lookup(document, "defaults")
+	if !ok || value == nil {
+		return inherited, nil
+	}
+	defaults, ok := value.(map[string]any)
+	if !ok {
+		return nil, newTaskInvalidPropertyError("defaults", fmt.Sprint(value), "must be an object")
+	}
+	data, _ := json.Marshal(defaults)
+	if errs := checkPropertyNames(data, reflect.TypeFor[Task]()); len(errs) != 0 {
+		return nil, errs[0]
+	}
+	for _, property := range UNINHERITED_PROPERTIES {
+		if value, ok := lookup(defaults, property); ok {
+			return nil, newTaskInvalidPropertyError(property, fmt.Sprint(value), "cannot have a default value")
+		}
+	}
+	return merge(inherited, defaults), nil
+}
+
+// Get the result of layering, from the bottom up: `defaults`, the tasks
+// they extend and their own properties, for each of `tasks`. Tasks that
+// cannot be resolved have an error instead.
+func resolveEachTask(tasks []any, defaults map[string]any) (resolved []any, errs []error) {
+	named := map[string]map[string]any{}
+	for _, task := range tasks {
+		if object, ok := task.(map[string]any); ok {
+			if name, ok := lookup(object, "name"); ok {
+				if name, ok := name.(string); ok && named[name] == nil {
+					named[name] = object
+				}
+			}
+		}
+	}
+
+	var resolve func(task map[string]any, visited []string) (map[string]any, error)
+	resolve = func(task map[string]any, visited []string) (map[string]any, error) {
+		value, ok := lookup(task, "extends")
+		if !ok {
+			return merge(defaults, task), nil
+		}
+		name, ok := value.(string)
+		if !ok {
+			return nil, newTaskInvalidPropertyError("extends", fmt.Sprint(value), "must be the name of a task")
+		}
+		if slices.Contains(visited, name) {
+			return nil, newTaskInvalidPropertyError("extends", name, "a task cannot extend itself")
+		}
+		parent, ok := named[name]
+		if !ok {
+			return nil, newTaskInvalidPropertyError("extends", name, "no task has this name")
+		}
+		base, err := resolve(parent, append(visited, name))
+		if err != nil {
+			return nil, err
+		}
+		return merge(without(base, UNINHERITED_PROPERTIES), task), nil
+	}
+
+	resolved = make([]any, len(tasks))
+	errs = make([]error, len(tasks))
+	for i, task := range tasks {
+		object, ok := task.(map[string]any)
+		if !ok {
+			resolved[i] = task
+			continue
+		}
+		visited := []string{}
+		if name, ok := lookup(object, "name"); ok {
+			visited = append(visited, fmt.Sprint(name))
+		}
+		if result, err := resolve(object, visited); err != nil {
+			errs[i] = err
+		} else {
+			resolved[i] = without(result, []string{"extends"})
+		}
+	}
+	return resolved, errs
+}
+
+// Replace the tasks of a configuration decoded from any format by their
+// resolution with resolveEachTask. `index` is the first task that cannot be
+// resolved, or -1 if every task was resolved.
+func resolveTasks(document any, defaults map[string]any) (index int, err error) {
+	value, _ := lookup(document, "tasks")
+	tasks, ok := value.([]any)
+	if !ok {
+		return -1, nil
+	}
+	resolved, errs := resolveEachTask(tasks, defaults)
+	for i, err := range errs {
+		if err != nil {
+			return i, err
+		}
+	}
+	copy(tasks, resolved)
+	return -1, nil
+}
+
+// Resolve the defaults and the inheritance of the tasks of a JSON
+// configuration, layered on top of `inherited`, and its relative
+// environment files against `dir`. Documents that are not valid JSON are
+// left untouched, for their decoding to report the error.
+func inherit(data []byte, inherited map[string]any, dir string) ([]byte, error) {
+	var document any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return data, nil
+	}
+	resolveEnvironmentFiles(document, dir)
+
+	defaults, err := resolveDefaults(document, inherited)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := resolveTasks(document, defaults); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Get the value of `key` in `value` if it is an object, matching keys the
+// same way as encoding/json does
+func lookup(value any, key string) (any, bool) {
+	if object, ok := value.(map[string]any); ok {
+		for k, v := range object {
+			if strings.EqualFold(k, key) {
+				return v, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Get the property of a task or of the configuration an error is about, if any
+func errorProperty(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	var invalidErr TaskInvalidPropertyError
+	var enumErr TaskEnumPropertyError
+	var unknownErr UnknownPropertyError
+
+	switch {
+	case errors.As(err, &typeErr):
+		property, _, _ := strings.Cut(typeErr.Field, ".")
+		return property
+	case errors.As(err, &unknownErr):
+		return unknownErr.property
+	case errors.As(err, &enumErr):
+		return enumErr.property
+	case errors.As(err, &invalidErr):
+		return invalidErr.property
+	}
+	return ""
+}
+
+// Find the first invalid task of a configuration decoded from any format,
+// by validating each task on its own. `index` is -1 if every task is valid.
+func findInvalidTask(document any) (index int, err error) {
+	defaults, err := resolveDefaults(document, map[string]any{})
+	if err != nil {
+		return -1, nil
+	}
+	if index, err := resolveTasks(document, defaults); err != nil {
+		return index, err
+	}
+	tasks, _ := lookup(document, "tasks")
+	list, _ := tasks.([]any)
+	for i, value := range list {
+		var task Task
+		data, _ := json.Marshal(value)
+		if err := json.Unmarshal(data, &task); err != nil {
+			return i, err
+		}
+	}
+	return -1, nil
+}
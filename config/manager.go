@@ -1,6 +1,7 @@
 package config
 
 import (
+	"sync"
 	"taskmaster/atom"
 )
 
@@ -8,11 +9,17 @@ type Manager interface {
 	Get() (value *Config)
 	Subscribe(hook atom.AtomSubscriberFunc[*Config])
 	Load() error
+	// Plan the changes loading the configuration would make, without
+	// loading it
+	Plan() (ReloadPlan, error)
 }
 
 type manager struct {
 	atom atom.Atom[*Config]
 	path string
+	// Held while loading, for the configuration files to be applied in the
+	// order they are read
+	loading *sync.Mutex
 }
 
 func (this *manager) Get() *Config {
@@ -24,8 +31,12 @@ func (this *manager) Subscribe(hook atom.AtomSubscriberFunc[*Config]) {
 }
 
 func (this *manager) Load() error {
+	this.loading.Lock()
+	defer this.loading.Unlock()
 	if config, err := Parse(this.path); err != nil {
 		return err
+	} else if err := config.createLogDir(); err != nil {
+		return err
 	} else {
 		if _, err := this.atom.Set(config); err != nil {
 			return err
@@ -34,8 +45,16 @@ func (this *manager) Load() error {
 	return nil
 }
 
+func (this *manager) Plan() (ReloadPlan, error) {
+	if config, err := Parse(this.path); err != nil {
+		return ReloadPlan{}, err
+	} else {
+		return PlanReload(this.Get(), config), nil
+	}
+}
+
 func NewManager(path string) (Manager, error) {
-	instance := &manager{path: path, atom: atom.NewAtom[*Config](nil)}
+	instance := &manager{path: path, atom: atom.NewAtom[*Config](nil), loading: new(sync.Mutex)}
 	if err := instance.Load(); err != nil {
 		return nil, err
 	}
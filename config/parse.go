@@ -2,11 +2,19 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
+	"path/filepath"
+	"reflect"
+	"slices"
 )
 
+const DEFAULT_SOCKET = "/tmp/taskmaster.sock"
+
+// Extensions of the supported configuration file formats
+var FORMATS = []string{".json", ".yaml", ".yml", ".toml", ".conf", ".ini"}
+
 type ParseError struct {
 	cause string
 }
@@ -19,39 +27,165 @@ func newParseError(cause string) ParseError {
 	return ParseError{cause}
 }
 
-func Parse(path string) (*Config, error) {
-	if !strings.HasSuffix(path, ".json") {
-		return nil, newParseError("Invalid config file format (expected a json file)")
+// Decode the configuration file at `path` into `value`, whatever its
+// format, with `defaults` beneath the defaults of the file. Returns the
+// warnings about what could not be imported from a supervisord file.
+func decode(path string, value any, defaults map[string]any) (warnings []string, err error) {
+	extension := filepath.Ext(path)
+	if !slices.Contains(FORMATS, extension) {
+		return nil, errors.New("Invalid config file format (expected a json, yaml, yml, toml, conf or ini file)")
 	}
 
-	config := Config{
-		Tasks:  []Task{},
-		LogDir: "/var/log/taskmaster",
-	}
 	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Errors are located in the file when its format allows it
+	locate := func(err error) error { return err }
+	switch extension {
+	case ".yaml", ".yml":
+		root, converted, err := yamlToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+		locate = func(err error) error { return errors.New(locateYAMLError(root, err)) }
+	case ".toml":
+		document, converted, err := tomlToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		source := data
+		data = converted
+		locate = func(err error) error { return errors.New(locateTOMLError(source, document, err)) }
+	case ".conf", ".ini":
+		imported, importWarnings, err := ImportSupervisord(path)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(imported); err != nil {
+			return nil, err
+		}
+		for _, warning := range importWarnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", path, warning))
+		}
+	}
+
+	if errs := checkPropertyNames(data, reflect.TypeFor[Config]()); len(errs) != 0 {
+		return nil, locate(errs[0])
+	}
+	if data, err = inherit(data, defaults, filepath.Dir(path)); err != nil {
+		return nil, locate(err)
+	}
+	if err = json.Unmarshal(data, value); err != nil {
+		return nil, locate(err)
+	}
+	return warnings, nil
+}
+
+// Add the tasks of the files matching the `include` globs of the
+// configuration, relative to the directory of the configuration file.
+// Included files only contribute their tasks.
+func include(path string, config *Config, sources map[string]string) error {
+	for _, pattern := range config.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("Invalid include pattern %s (%s)", pattern, err)
+		}
+
+		for _, match := range matches {
+			if same, err := sameFile(match, path); err != nil || same {
+				continue
+			}
+			fragment := struct{ Tasks []Task }{[]Task{}}
+			warnings, err := decode(match, &fragment, config.Defaults)
+			if err != nil {
+				return fmt.Errorf("%s: %s", match, err)
+			}
+			config.warnings = append(config.warnings, warnings...)
+			for _, task := range fragment.Tasks {
+				if err := addTask(config, task, match, sources); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func sameFile(first, second string) (bool, error) {
+	firstInfo, err := os.Stat(first)
+	if err != nil {
+		return false, err
+	}
+	secondInfo, err := os.Stat(second)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(firstInfo, secondInfo), nil
+}
+
+// Add `task` to the configuration, unless a task with the same name comes
+// from another file (or twice from the same one).
+func addTask(config *Config, task Task, source string, sources map[string]string) error {
+	if other, ok := sources[*task.Name]; ok {
+		return fmt.Errorf("Multiple tasks with the same name: %s (in %s and %s)", *task.Name, other, source)
+	}
+	sources[*task.Name] = source
+	config.Tasks = append(config.Tasks, task)
+	return nil
+}
+
+// Get a configuration with the default values of its properties
+func newConfig() Config {
+	return Config{
+		Tasks:         []Task{},
+		LogDir:        "/var/log/taskmaster",
+		Socket:        DEFAULT_SOCKET,
+		Http:          "",
+		Watch:         false,
+		Notifications: []Webhook{},
+		Acl:           []AclRule{},
+		Include:       []string{},
+		Defaults:      map[string]any{},
+	}
+}
+
+func Parse(path string) (*Config, error) {
+	config := newConfig()
+	warnings, err := decode(path, &config, map[string]any{})
 	if err != nil {
 		return nil, newParseError(err.Error())
 	}
-	if err = json.Unmarshal(data, &config); err != nil {
+	config.warnings = warnings
+
+	tasks := config.Tasks
+	config.Tasks = make([]Task, 0, len(tasks))
+	sources := map[string]string{}
+	for _, task := range tasks {
+		if err := addTask(&config, task, path, sources); err != nil {
+			return nil, newParseError(err.Error())
+		}
+	}
+	if err := include(path, &config, sources); err != nil {
 		return nil, newParseError(err.Error())
 	}
 
 	if len(config.Tasks) == 0 {
 		return nil, newParseError("No task to run")
 	}
-	
-	name_map := make(map[string]bool)
-	for i := 0; i < len(config.Tasks); i++ {  
-		name := *config.Tasks[i].Name
-		if name_map[name] {
-			return nil, newParseError("Multiple tasks with the same name.")
-		}
-		name_map[name] = true
-	}    
-
-	if err := os.MkdirAll(config.LogDir, os.ModePerm); err != nil {
-		return nil, newParseError(fmt.Sprintf("Failed to open log directory (%s)", err))
-	}
 
 	return &config, nil
 }
+
+// Create the log directory of the configuration, which Parse leaves to its
+// users for the configuration files to be read without side effects
+func (this *Config) createLogDir() error {
+	if err := os.MkdirAll(this.LogDir, os.ModePerm); err != nil {
+		return newParseError(fmt.Sprintf("Failed to open log directory (%s)", err))
+	}
+	return nil
+}
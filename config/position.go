@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position of a property in a configuration file, from 1
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Positions of the properties of a configuration file, by path (such as
+// "tasks[1].restart"), in lowercase since keys match the same way as
+// encoding/json does
+type positions map[string]Position
+
+func (this positions) get(path string) Position {
+	path = strings.ToLower(path)
+	for {
+		if position, ok := this[path]; ok {
+			return position
+		}
+		// Fall back to the closest parent that can be found
+		parent := strings.LastIndexAny(path, ".[")
+		if parent == -1 {
+			return Position{}
+		}
+		path = path[:parent]
+	}
+}
+
+func joinPath(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func indexPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+// Get the position of a byte offset in `data`
+func offsetPosition(data []byte, offset int) Position {
+	offset = min(offset, len(data))
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return Position{line, column}
+}
+
+// Get the positions of the keys and array items of a JSON document
+func jsonPositions(data []byte) positions {
+	result := positions{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		delim, ok := token.(json.Delim)
+		if !ok {
+			return nil
+		}
+		// Properties are located at their key, array items at their start
+		if _, ok := result[path]; !ok {
+			result[path] = offsetPosition(data, int(decoder.InputOffset())-1)
+		}
+
+		for i := 0; decoder.More(); i++ {
+			if delim == '[' {
+				if err := walk(indexPath(path, i)); err != nil {
+					return err
+				}
+				continue
+			}
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			quoted, _ := json.Marshal(key)
+			keyPath := joinPath(path, strings.ToLower(fmt.Sprint(key)))
+			result[keyPath] = offsetPosition(data, int(decoder.InputOffset())-len(quoted))
+			if err := walk(keyPath); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+	walk("")
+	return result
+}
+
+// Get the positions of the keys and sequence items of a YAML document
+func yamlPositions(root *yaml.Node) positions {
+	result := positions{}
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			for _, child := range node.Content {
+				walk(child, path)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i]
+				keyPath := joinPath(path, strings.ToLower(key.Value))
+				result[keyPath] = Position{key.Line, key.Column}
+				walk(node.Content[i+1], keyPath)
+			}
+		case yaml.SequenceNode:
+			for i, child := range node.Content {
+				result[indexPath(path, i)] = Position{child.Line, child.Column}
+				walk(child, indexPath(path, i))
+			}
+		}
+	}
+	walk(root, "")
+	return result
+}
+
+// Get the positions of the keys and tables of a TOML document. Only the
+// common layouts are understood: keys, [tables] and [[arrays of tables]].
+func tomlPositions(data []byte) positions {
+	result := positions{}
+	counts := map[string]int{}
+	table := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		if name, isArray, ok := tomlTableHeader(line); ok {
+			name = strings.ToLower(name)
+			if isArray {
+				table = indexPath(name, counts[name])
+				counts[name]++
+			} else if parent, key, ok := strings.Cut(name, "."); ok && counts[parent] != 0 {
+				// Sub-table of the last table of an array, such as
+				// [tasks.environment]
+				table = joinPath(indexPath(parent, counts[parent]-1), key)
+			} else {
+				table = name
+			}
+			result[table] = Position{i + 1, strings.Index(line, "[") + 1}
+			continue
+		}
+		name, _, ok := strings.Cut(line, "=")
+		if !ok || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(name), `"'`)
+		if len(key) == 0 {
+			continue
+		}
+		column := strings.Index(line, strings.TrimSpace(name)) + 1
+		result[joinPath(table, strings.ToLower(key))] = Position{i + 1, column}
+	}
+	return result
+}
+
+// Get the path of the property an error is about, relative to the object
+// that was decoded
+func errorPath(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && len(typeErr.Field) != 0 {
+		path := ""
+		for _, part := range strings.Split(typeErr.Field, ".") {
+			if index, err := strconv.Atoi(part); err == nil {
+				path = indexPath(path, index)
+			} else {
+				path = joinPath(path, part)
+			}
+		}
+		return path
+	}
+	return errorProperty(err)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// What a reload does to the processes of a task
+const (
+	TASK_ADDED     = "added"
+	TASK_REMOVED   = "removed"
+	TASK_RESTARTED = "restarted"
+	TASK_SCALED    = "scaled"
+	TASK_UPDATED   = "updated"
+	TASK_UNCHANGED = "unchanged"
+)
+
+// Properties of a task whose new value is used by its running processes
+// without restarting them. Changing `instances` starts or stops the last
+// instances only, unless the templates of the task use the number of
+// instances, and changing any other property restarts every process.
+var LIVE_PROPERTIES = []string{"startAtLaunch", "restart", "restartAttempts", "expectedExitStatus", "startTime", "stopTime", "stopSignal"}
+
+// Properties of the configuration whose change restarts every task
+var RESTARTING_PROPERTIES = []string{"logDir"}
+
+// How a reload changes a task
+type TaskChange struct {
+	Name   string
+	Action string
+	// Changed properties, in the order of their definition
+	Properties []string
+	// Numbers of instances before and after the reload
+	Instances     uint
+	NextInstances uint
+}
+
+// Describe what the change does to the task and why
+func (this TaskChange) String() string {
+	properties := strings.Join(this.Properties, ", ")
+	switch this.Action {
+	case TASK_ADDED:
+		return fmt.Sprintf("%s: added, starting %d instances", this.Name, this.NextInstances)
+	case TASK_REMOVED:
+		return fmt.Sprintf("%s: removed, stopping %d instances", this.Name, this.Instances)
+	case TASK_RESTARTED:
+		return fmt.Sprintf("%s: restarted, %s changed", this.Name, properties)
+	case TASK_SCALED:
+		return fmt.Sprintf("%s: scaled from %d to %d instances, %s changed", this.Name, this.Instances, this.NextInstances, properties)
+	case TASK_UPDATED:
+		return fmt.Sprintf("%s: updated without restarting, %s changed", this.Name, properties)
+	default:
+		return fmt.Sprintf("%s: unchanged", this.Name)
+	}
+}
+
+// Changes of the tasks from a configuration to the next one
+type ReloadPlan struct {
+	Tasks []TaskChange
+}
+
+// Get the properties that differ between two definitions of a type
+func changedProperties(current, next any) []string {
+	currentValue, nextValue := reflect.ValueOf(current), reflect.ValueOf(next)
+	changed := []string{}
+	for i := range currentValue.NumField() {
+		field := currentValue.Type().Field(i)
+		if field.IsExported() && !reflect.DeepEqual(currentValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			changed = append(changed, propertyName(field))
+		}
+	}
+	return changed
+}
+
+// Classify the changes of a task, `global` being the changed properties of
+// the configuration that restart every task
+func planTask(current, next *Task, global []string) TaskChange {
+	change := TaskChange{
+		Name:          *next.Name,
+		Action:        TASK_UNCHANGED,
+		Properties:    append(slices.Clone(global), changedProperties(*current, *next)...),
+		Instances:     current.Instances,
+		NextInstances: next.Instances,
+	}
+	for _, property := range change.Properties {
+		switch {
+		case property == "instances" && next.dependsOnInstanceCount(current.Instances, next.Instances):
+			change.Action = TASK_RESTARTED
+		case property == "instances":
+			if change.Action != TASK_RESTARTED {
+				change.Action = TASK_SCALED
+			}
+		case slices.Contains(LIVE_PROPERTIES, property):
+			if change.Action == TASK_UNCHANGED {
+				change.Action = TASK_UPDATED
+			}
+		default:
+			change.Action = TASK_RESTARTED
+		}
+	}
+	return change
+}
+
+// Plan how the tasks of `current` become the ones of `next`. Tasks are
+// matched by name, whatever their position in the configuration. The plan
+// has the changes of the tasks of `next`, in the same order, followed by
+// the tasks that are removed.
+func PlanReload(current, next *Config) ReloadPlan {
+	global := []string{}
+	for _, property := range changedProperties(*current, *next) {
+		if slices.Contains(RESTARTING_PROPERTIES, property) {
+			global = append(global, property)
+		}
+	}
+
+	byName := map[string]*Task{}
+	for i := range current.Tasks {
+		byName[*current.Tasks[i].Name] = &current.Tasks[i]
+	}
+
+	plan := ReloadPlan{Tasks: []TaskChange{}}
+	for i := range next.Tasks {
+		task := &next.Tasks[i]
+		if currentTask, ok := byName[*task.Name]; ok {
+			plan.Tasks = append(plan.Tasks, planTask(currentTask, task, global))
+			delete(byName, *task.Name)
+		} else {
+			plan.Tasks = append(plan.Tasks, TaskChange{
+				Name:          *task.Name,
+				Action:        TASK_ADDED,
+				NextInstances: task.Instances,
+			})
+		}
+	}
+	for _, task := range current.Tasks {
+		if _, ok := byName[*task.Name]; ok {
+			plan.Tasks = append(plan.Tasks, TaskChange{
+				Name:      *task.Name,
+				Action:    TASK_REMOVED,
+				Instances: task.Instances,
+			})
+		}
+	}
+	return plan
+}
@@ -0,0 +1,576 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Documentation and constraints of a property of the configuration. The
+// rest of its schema (name, type, items and default value) comes from the
+// Go definition, for the schema and the decoding to stay in sync.
+type propertyDoc struct {
+	description string
+	// Replaces the "Default is ..." line of the description
+	defaultDescription string
+	enum               []string
+	pattern            string
+	minimum            *int
+	maximum            *int
+	// Whether null is a valid value, and the default one
+	nullable bool
+	required bool
+	// Required properties of the items of an array
+	itemsRequired []string
+	// Definition the property is an instance of, instead of its Go type
+	ref string
+	// Properties the value of `ref` cannot have
+	forbidden []string
+}
+
+// A property of the configuration files without a Go field, always a string
+type extraProperty struct {
+	name string
+	doc  propertyDoc
+}
+
+// Documentation of an object of the configuration
+type objectDoc struct {
+	description string
+	// Of each Go field, by field name
+	properties map[string]propertyDoc
+	extra      []extraProperty
+	// Sets of properties of which at least one is required
+	anyOfRequired [][]string
+	// Value holding the default values of the properties
+	defaults func() any
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+var SCHEMA_DOCS = map[reflect.Type]objectDoc{
+	reflect.TypeFor[Config](): {
+		description: "TaskMaster configuration file",
+		extra: []extraProperty{{"$schema", propertyDoc{
+			description: "The JSON schema of the file, for editors",
+		}}},
+		anyOfRequired: [][]string{{"tasks"}, {"include"}},
+		defaults:      func() any { return newConfig() },
+		properties: map[string]propertyDoc{
+			"Tasks": {
+				description:   "The tasks to be executed",
+				itemsRequired: []string{"name"},
+			},
+			"LogDir": {
+				description: "The path in which to save output log files",
+			},
+			"Socket": {
+				description: "The path of the UNIX socket used to control taskmaster when it runs as a daemon",
+			},
+			"Http": {
+				description: "The address on which to serve the HTTP API, either host:port or unix:/path/to/socket, or an empty string to disable it. Requests over TCP are not authenticated and must name the listen address or a loopback host (localhost, 127.0.0.1, [::1]) in their Host header, only requests over a UNIX socket are authorized with the ACL",
+			},
+			"Watch": {
+				description: "Whether to reload the configuration when the configuration file, the files it includes or the environment files of its tasks change, once they stop changing for half a second (Linux only, can also be enabled with the -watch flag)\nThe results of these reloads are only reported in the log and the event stream",
+			},
+			"Notifications": {
+				description: "Webhooks receiving a POST request with a JSON payload when something goes wrong",
+			},
+			"Acl": {
+				description:        "Who may use the UNIX sockets (control socket, and HTTP API when served on a UNIX socket), by user or group id\nThe user running taskmaster and root are always operators",
+				defaultDescription: "Default is [] (only the user running taskmaster and root may connect)",
+			},
+			"Include": {
+				description: "Glob patterns of other configuration files (in any supported format) whose tasks are added to this configuration, relative to the directory of this file\nTask names must be unique across all files",
+			},
+			"Defaults": {
+				description: "Default values of the properties of the tasks, in this file and in the included files, applied before 'extends'\nObjects such as 'environment' are merged instead of replaced",
+				ref:         "task",
+				forbidden:   UNINHERITED_PROPERTIES,
+			},
+		},
+	},
+
+	reflect.TypeFor[Webhook](): {
+		defaults: func() any { return newWebhook() },
+		properties: map[string]propertyDoc{
+			"Url": {
+				description: "The URL to send the notifications to",
+				pattern:     "^https?://",
+				required:    true,
+			},
+			"Events": {
+				description:        "The events to notify\n'unexpected-exit': a process exited with an unexpected status without being stopped\n'retries-exhausted': a process will not be restarted because it has no restart attempts left\n'failed-to-start': a process could not be started\n'reload-failed': the configuration could not be reloaded",
+				defaultDescription: "Default is every event",
+				enum:               WEBHOOK_EVENTS_VALUES,
+			},
+			"Retries": {
+				description: "The number of times to try again to send a notification that failed",
+			},
+			"Backoff": {
+				description: "The time to wait before trying again to send a notification, doubled after each failure, in milliseconds or as a duration like '10s'",
+			},
+		},
+	},
+
+	reflect.TypeFor[AclRule](): {
+		anyOfRequired: [][]string{{"uid"}, {"gid"}},
+		defaults:      func() any { return AclRule{} },
+		properties: map[string]propertyDoc{
+			"Uid": {
+				description: "The user id the rule applies to",
+			},
+			"Gid": {
+				description: "The group id the rule applies to, matching the primary and supplementary groups of the user",
+			},
+			"Role": {
+				description: "'read-only': may only get the status of the tasks\n'operator': may also start, stop and restart processes, reload the configuration and shut taskmaster down",
+				enum:        ROLE_VALUES,
+				required:    true,
+			},
+		},
+	},
+
+	reflect.TypeFor[Task](): {
+		description: "A task, whose command, arguments, workingDirectory, environment values and log files may contain Go templates\nAvailable values: {{.TaskName}}, {{.Instance}} (from 0), {{.InstanceCount}}, {{.LogDir}}, {{.Hostname}}, {{.User}}, {{.Home}}\nAvailable functions: add, sub, mul (e.g. {{add 8000 .Instance}})",
+		// Resolved before the tasks are decoded
+		extra: []extraProperty{{"extends", propertyDoc{
+			description: "The name of a task (in the same file) whose properties, except its name, are inherited\nObjects such as 'environment' are merged instead of replaced",
+		}}},
+		defaults: func() any { return newTask() },
+		properties: map[string]propertyDoc{
+			"Name": {
+				description: "The name of the task",
+				pattern:     NAME_PATTERN,
+			},
+			"Command": {
+				description: "The executable to run\nMay contain templates such as {{.Instance}}",
+			},
+			"Arguments": {
+				description: "The arguments to pass to the executable\nMay contain templates such as {{.Instance}}",
+			},
+			"StartAtLaunch": {
+				description: "Whether or not to start the process(es) at launch",
+			},
+			"Instances": {
+				description: "The number of instances of the process to run",
+				minimum:     intPointer(1),
+			},
+			"Restart": {
+				description: "'always': always restart the process if it exits\n'never': never restart the process\n'on-failure': restart if the process exits with an error code\n'unless-stopped': restart the process except if the user stops it manually through the console",
+				enum:        RESTART_VALUES,
+			},
+			"RestartAttempts": {
+				description: "The number of restart attempts (or 0 for infinite attempts), unused when 'restart' is 'never'",
+			},
+			"ExpectedExitStatus": {
+				description: "The expected success exit status code for the process(es)",
+			},
+			"StartTime": {
+				description: "The time to wait before considering that a process is successfully started, in milliseconds or as a duration like '10s'",
+			},
+			"StopTime": {
+				description: "The time to wait after a graceful stop before killing a process, in milliseconds or as a duration like '10s'",
+			},
+			"StopSignal": {
+				description: "The signal used to quit a process gracefully",
+				enum:        STOP_SIGNAL_VALUES,
+			},
+			"Stdout": {
+				description: "'ignore': ignore stdout\n'redirect': redirect stdout to a log file",
+				enum:        STDIO_VALUES,
+			},
+			"Stderr": {
+				description: "'ignore': ignore stderr\n'redirect': redirect stderr to a log file",
+				enum:        STDIO_VALUES,
+			},
+			"StdoutLogFile": {
+				description:        "The file stdout is redirected to, relative to 'logDir', when 'stdout' is 'redirect'\nMay contain templates such as {{.Instance}}",
+				defaultDescription: "Default is '' (a new file in 'logDir' for each run of taskmaster)",
+			},
+			"StderrLogFile": {
+				description:        "The file stderr is redirected to, relative to 'logDir', when 'stderr' is 'redirect'\nMay contain templates such as {{.Instance}}",
+				defaultDescription: "Default is '' (a new file in 'logDir' for each run of taskmaster)",
+			},
+			"Environment": {
+				description: "Variables to pass as environment to the process(es)\nValues may contain templates such as {{.Instance}}",
+			},
+			"EnvironmentFiles": {
+				description: "Dotenv files (KEY=value lines, with '#' comments, quoting, an optional 'export' prefix and ${VAR} expansion) whose variables are passed as environment to the process(es), beneath 'environment'\nRelative paths are relative to the directory of the file declaring them, and later files override earlier ones",
+			},
+			"WorkingDirectory": {
+				description: "The working directory\nMay contain templates such as {{.Instance}}",
+			},
+			"Permissions": {
+				description:        "The permissions umask to set before launching the program, or null to leave the permissions as they are",
+				defaultDescription: "The master process's umask is used by default",
+				maximum:            intPointer(777),
+				nullable:           true,
+			},
+			"Events": {
+				description:        "Make the task an event listener, receiving these kinds of events on its stdin and acknowledging them on its stdout\n'process': process state changes\n'reload': configuration reload results\n'log': lines written by the other processes",
+				defaultDescription: "Default is [] (not an event listener)",
+				enum:               TASK_EVENTS_VALUES,
+			},
+		},
+	},
+}
+
+// Objects of the schema defined once and referenced by their name
+var SCHEMA_DEFINITIONS = map[string]reflect.Type{
+	"task": reflect.TypeFor[Task](),
+}
+
+// Name of the property of the configuration files decoded into `field`
+func propertyName(field reflect.StructField) string {
+	first, size := utf8.DecodeRuneInString(field.Name)
+	return string(unicode.ToLower(first)) + field.Name[size:]
+}
+
+// Names of the properties of the objects of the configuration files decoded
+// into `typ`
+func propertyNames(typ reflect.Type) []string {
+	names := []string{}
+	for _, extra := range SCHEMA_DOCS[typ].extra {
+		names = append(names, extra.name)
+	}
+	for i := range typ.NumField() {
+		if field := typ.Field(i); field.IsExported() {
+			names = append(names, propertyName(field))
+		}
+	}
+	return names
+}
+
+type UnknownPropertyError struct {
+	TaskPropertyError
+	suggestion string
+}
+
+func (this UnknownPropertyError) Error() string {
+	if len(this.suggestion) == 0 {
+		return fmt.Sprintf("Unknown property %s", this.property)
+	}
+	return fmt.Sprintf("Unknown property %s (did you mean %s?)", this.property, this.suggestion)
+}
+
+// Number of single-character edits between `a` and `b`
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// Suggest the known property closest to `property`, if one is close enough
+func newUnknownPropertyError(property string, known []string) UnknownPropertyError {
+	suggestion := ""
+	best := 3
+	for _, name := range known {
+		distance := editDistance(strings.ToLower(property), strings.ToLower(name))
+		if distance < best {
+			suggestion, best = name, distance
+		}
+	}
+	return UnknownPropertyError{TaskPropertyError{property}, suggestion}
+}
+
+// Get an error for each key of the JSON object `data` that is not a property
+// of the objects decoded into `typ`. Unlike encoding/json, keys must match
+// exactly, for typos such as "stopsignal" not to go unnoticed. Data that is
+// not an object is left to the decoding to report.
+func checkPropertyNames(data []byte, typ reflect.Type) []error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil
+	}
+	known := propertyNames(typ)
+	errs := []error{}
+	for _, key := range slices.Sorted(maps.Keys(object)) {
+		if !slices.Contains(known, key) {
+			errs = append(errs, newUnknownPropertyError(key, known))
+		}
+	}
+	return errs
+}
+
+// An object of the schema, keeping its keys in order
+type schemaObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func newSchemaObject() *schemaObject {
+	return &schemaObject{keys: []string{}, values: map[string]any{}}
+}
+
+func (this *schemaObject) set(key string, value any) *schemaObject {
+	if _, ok := this.values[key]; !ok {
+		this.keys = append(this.keys, key)
+	}
+	this.values[key] = value
+	return this
+}
+
+func (this *schemaObject) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+	for i, key := range this.keys {
+		if i != 0 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		value, err := json.Marshal(this.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+func requiredSchemas(sets [][]string) []any {
+	schemas := []any{}
+	for _, set := range sets {
+		schemas = append(schemas, newSchemaObject().set("required", set))
+	}
+	return schemas
+}
+
+func formatDefault(value any) string {
+	if text, ok := value.(string); ok {
+		return fmt.Sprintf("'%s'", text)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// Get the schema of the values of type `typ`, `doc` being the documentation
+// of the property holding them
+func typeSchema(typ reflect.Type, doc propertyDoc) (*schemaObject, error) {
+	schema := newSchemaObject()
+	if typ == reflect.TypeFor[Duration]() {
+		return schema.set("oneOf", []any{
+			newSchemaObject().set("type", "integer").set("minimum", 0),
+			newSchemaObject().set("type", "string").set("pattern", DURATION_PATTERN),
+		}), nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Pointer:
+		return typeSchema(typ.Elem(), doc)
+
+	case reflect.String:
+		if len(doc.enum) != 0 {
+			schema.set("enum", doc.enum)
+		} else {
+			schema.set("type", "string")
+		}
+		if len(doc.pattern) != 0 {
+			schema.set("pattern", doc.pattern)
+		}
+
+	case reflect.Bool:
+		schema.set("type", "boolean")
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema.set("type", "integer")
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema.set("type", "integer")
+		if doc.minimum == nil {
+			doc.minimum = intPointer(0)
+		}
+
+	case reflect.Slice:
+		items, err := typeSchema(typ.Elem(), propertyDoc{enum: doc.enum})
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.itemsRequired) != 0 {
+			items.set("required", doc.itemsRequired)
+		}
+		schema.set("type", "array").set("items", items)
+
+	case reflect.Map:
+		values, err := typeSchema(typ.Elem(), propertyDoc{})
+		if err != nil {
+			return nil, err
+		}
+		schema.set("type", "object").set("additionalProperties", values)
+
+	case reflect.Struct:
+		for name, definition := range SCHEMA_DEFINITIONS {
+			if definition == typ {
+				return schema.set("$ref", "#/$defs/"+name), nil
+			}
+		}
+		return objectSchema(typ)
+
+	default:
+		return nil, fmt.Errorf("no schema for the type %s", typ)
+	}
+
+	if doc.minimum != nil {
+		schema.set("minimum", *doc.minimum)
+	}
+	if doc.maximum != nil {
+		schema.set("maximum", *doc.maximum)
+	}
+	return schema, nil
+}
+
+// Get the schema of a property, with `value` the default value of its field
+func propertySchema(typ reflect.Type, doc propertyDoc, value reflect.Value) (*schemaObject, error) {
+	var schema *schemaObject
+	if len(doc.ref) != 0 {
+		schema = newSchemaObject().set("$ref", "#/$defs/"+doc.ref)
+	} else {
+		var err error
+		if schema, err = typeSchema(typ, doc); err != nil {
+			return nil, err
+		}
+	}
+	if doc.nullable {
+		schema.set("type", []string{schema.values["type"].(string), "null"})
+	}
+
+	description := doc.description
+	hasDefault := !doc.required && value.IsValid() && (value.Kind() != reflect.Pointer || !value.IsNil() || doc.nullable)
+	if hasDefault {
+		schema.set("default", value.Interface())
+		if len(doc.defaultDescription) != 0 {
+			description += "\n" + doc.defaultDescription
+		} else {
+			description += "\nDefault is " + formatDefault(value.Interface())
+		}
+	}
+	schema.set("description", description)
+
+	if len(doc.forbidden) != 0 {
+		schema.set("not", newSchemaObject().set("anyOf", requiredSchemas(eachOf(doc.forbidden))))
+	}
+	return schema, nil
+}
+
+// Split `properties` into sets of one property
+func eachOf(properties []string) [][]string {
+	sets := [][]string{}
+	for _, property := range properties {
+		sets = append(sets, []string{property})
+	}
+	return sets
+}
+
+// Get the schema of the objects decoded into the struct `typ`, failing if
+// one of its fields is not documented in SCHEMA_DOCS
+func objectSchema(typ reflect.Type) (*schemaObject, error) {
+	doc, ok := SCHEMA_DOCS[typ]
+	if !ok {
+		return nil, fmt.Errorf("%s is not documented", typ)
+	}
+	schema := newSchemaObject().set("type", "object")
+	if len(doc.description) != 0 {
+		schema.set("description", doc.description)
+	}
+
+	defaults := reflect.ValueOf(doc.defaults())
+	properties := newSchemaObject()
+	required := []string{}
+	for _, extra := range doc.extra {
+		property, err := propertySchema(reflect.TypeFor[string](), extra.doc, reflect.Value{})
+		if err != nil {
+			return nil, err
+		}
+		properties.set(extra.name, property)
+	}
+	documented := []string{}
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldDoc, ok := doc.properties[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%s.%s is not documented", typ.Name(), field.Name)
+		}
+		documented = append(documented, field.Name)
+		property, err := propertySchema(field.Type, fieldDoc, defaults.FieldByIndex(field.Index))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", typ.Name(), field.Name, err)
+		}
+		properties.set(propertyName(field), property)
+		if fieldDoc.required {
+			required = append(required, propertyName(field))
+		}
+	}
+	for name := range doc.properties {
+		if !slices.Contains(documented, name) {
+			return nil, fmt.Errorf("%s.%s is documented but has no field", typ.Name(), name)
+		}
+	}
+
+	schema.set("properties", properties)
+	if len(doc.anyOfRequired) != 0 {
+		schema.set("anyOf", requiredSchemas(doc.anyOfRequired))
+	}
+	if len(required) != 0 {
+		schema.set("required", required)
+	}
+	return schema.set("additionalProperties", false), nil
+}
+
+// Generate the JSON schema of the configuration files (tmconfig.schema.json)
+// from the Go definition of the configuration
+func JSONSchema() ([]byte, error) {
+	root, err := objectSchema(reflect.TypeFor[Config]())
+	if err != nil {
+		return nil, err
+	}
+	schema := newSchemaObject().
+		set("$schema", "https://json-schema.org/draft/2020-12/schema").
+		set("$comment", "Generated from the Go definition of the configuration by `taskmaster schema`, do not edit").
+		set("title", "TMConfig")
+	for _, key := range root.keys {
+		schema.set(key, root.values[key])
+	}
+
+	definitions := newSchemaObject()
+	for _, name := range slices.Sorted(maps.Keys(SCHEMA_DEFINITIONS)) {
+		definition, err := objectSchema(SCHEMA_DEFINITIONS[name])
+		if err != nil {
+			return nil, err
+		}
+		definitions.set(name, definition)
+	}
+	schema.set("$defs", definitions)
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the schema (%s)", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,450 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"taskmaster/utils"
+)
+
+// Configuration converted from a supervisord file, in the same order as
+// the JSON schema
+type ImportedConfig struct {
+	Tasks  []ImportedTask `json:"tasks"`
+	Socket string         `json:"socket,omitempty"`
+}
+
+type ImportedTask struct {
+	Name               string            `json:"name"`
+	Command            string            `json:"command"`
+	Arguments          []string          `json:"arguments,omitempty"`
+	StartAtLaunch      bool              `json:"startAtLaunch"`
+	Instances          uint              `json:"instances"`
+	Restart            string            `json:"restart"`
+	RestartAttempts    uint              `json:"restartAttempts"`
+	ExpectedExitStatus int               `json:"expectedExitStatus"`
+	StartTime          uint              `json:"startTime"`
+	StopTime           uint              `json:"stopTime"`
+	StopSignal         string            `json:"stopSignal"`
+	Stdout             string            `json:"stdout"`
+	Stderr             string            `json:"stderr"`
+	StdoutLogFile      string            `json:"stdoutLogFile,omitempty"`
+	StderrLogFile      string            `json:"stderrLogFile,omitempty"`
+	Environment        map[string]string `json:"environment,omitempty"`
+	WorkingDirectory   string            `json:"workingDirectory,omitempty"`
+	Permissions        *uint             `json:"permissions,omitempty"`
+}
+
+type SupervisordError struct {
+	line  int
+	cause string
+}
+
+func (this SupervisordError) Error() string {
+	return fmt.Sprintf("Invalid supervisord configuration: line %d: %s", this.line, this.cause)
+}
+
+func newSupervisordError(line int, cause string) SupervisordError {
+	return SupervisordError{line, cause}
+}
+
+type iniValue struct {
+	value string
+	line  int
+}
+
+type iniSection struct {
+	name   string
+	line   int
+	values map[string]iniValue
+}
+
+// Parse an INI file the way supervisord does: "key = value" or "key: value"
+// lines, comments starting with ';' or '#', and indented continuation lines
+func parseINI(data []byte) ([]iniSection, error) {
+	sections := []iniSection{}
+	lastKey := ""
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(trimmed) == 0 || trimmed[0] == ';' || trimmed[0] == '#':
+			continue
+
+		case line[0] == ' ' || line[0] == '\t':
+			if len(sections) == 0 || len(lastKey) == 0 {
+				return nil, newSupervisordError(i+1, "unexpected indented line")
+			}
+			current := sections[len(sections)-1].values[lastKey]
+			current.value += "\n" + trimmed
+			sections[len(sections)-1].values[lastKey] = current
+
+		case trimmed[0] == '[':
+			name, ok := strings.CutSuffix(trimmed, "]")
+			if !ok {
+				return nil, newSupervisordError(i+1, fmt.Sprintf("invalid section header %s", trimmed))
+			}
+			sections = append(sections, iniSection{strings.TrimSpace(name[1:]), i + 1, map[string]iniValue{}})
+			lastKey = ""
+
+		default:
+			separator := strings.IndexAny(trimmed, "=:")
+			if separator == -1 {
+				return nil, newSupervisordError(i+1, fmt.Sprintf("expected a key and a value, got %s", trimmed))
+			}
+			if len(sections) == 0 {
+				return nil, newSupervisordError(i+1, "key outside of any section")
+			}
+			value := strings.TrimSpace(trimmed[separator+1:])
+			// Inline comments must be preceded by whitespace
+			if comment := strings.Index(value, " ;"); comment != -1 {
+				value = strings.TrimSpace(value[:comment])
+			}
+			lastKey = strings.ToLower(strings.TrimSpace(trimmed[:separator]))
+			sections[len(sections)-1].values[lastKey] = iniValue{value, i + 1}
+		}
+	}
+	return sections, nil
+}
+
+// Split a supervisord command line into words, the way a shell would
+// without expanding anything
+func splitCommand(command string) ([]string, error) {
+	words := []string{}
+	current := new(strings.Builder)
+	inWord := false
+	quote := rune(0)
+	escaped := false
+
+	for _, c := range command {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %s", command)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// Parse supervisord's KEY="value",KEY2=value2 environment lists
+func parseEnvironment(environment string) (map[string]string, error) {
+	result := map[string]string{}
+	for len(strings.TrimSpace(environment)) != 0 {
+		key, rest, ok := strings.Cut(environment, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected KEY=value, got %s", environment)
+		}
+		key = strings.TrimSpace(key)
+		rest = strings.TrimLeft(rest, " \t\n")
+
+		value := ""
+		if len(rest) != 0 && (rest[0] == '"' || rest[0] == '\'') {
+			end := strings.IndexByte(rest[1:], rest[0])
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated quote in the value of %s", key)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+			rest, _ = strings.CutPrefix(strings.TrimSpace(rest), ",")
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			value = strings.TrimSpace(value)
+		}
+		result[key] = value
+		environment = rest
+	}
+	return result, nil
+}
+
+// Expression of supervisord, with the flags and width of Python's %
+// formatting, e.g. "%(process_num)02d"
+var supervisordExpression = regexp.MustCompile(`%\(([a-zA-Z0-9_]+)\)([-#0 +]*[0-9]*)([sd])`)
+
+// Keys of [program:x] sections that have an equivalent
+var SUPERVISORD_PROGRAM_KEYS = []string{
+	"command", "numprocs", "autostart", "autorestart", "startsecs", "startretries", "exitcodes",
+	"stopsignal", "stopwaitsecs", "stdout_logfile", "stderr_logfile", "environment", "directory", "umask",
+}
+
+// Sections that only configure supervisord itself
+var SUPERVISORD_IGNORED_SECTIONS = []string{"supervisord", "supervisorctl", "inet_http_server"}
+
+type supervisordImporter struct {
+	here     string
+	warnings []string
+}
+
+func (this *supervisordImporter) warn(line int, format string, args ...any) {
+	this.warnings = append(this.warnings, fmt.Sprintf("line %d: %s", line, fmt.Sprintf(format, args...)))
+}
+
+// Expand the %(name)s expressions supervisord knows about at this point,
+// the ones only known once running becoming templates. The templates are
+// quoted when the value is a command, for them to stay whole when it is
+// split into words.
+func (this *supervisordImporter) expand(program string, value iniValue, isCommand bool) string {
+	expanded := supervisordExpression.ReplaceAllStringFunc(value.value, func(expression string) string {
+		match := supervisordExpression.FindStringSubmatch(expression)
+		name, format := match[1], "%"+match[2]
+		switch {
+		case name == "program_name" || name == "group_name":
+			return fmt.Sprintf(format+"s", program)
+		case name == "here":
+			return fmt.Sprintf(format+"s", this.here)
+		case name == "process_num":
+			return supervisordTemplate(format+match[3], "Instance", isCommand)
+		case name == "host_node_name":
+			return supervisordTemplate(format+"s", "Hostname", isCommand)
+		case strings.HasPrefix(name, "ENV_"):
+			return fmt.Sprintf(format+"s", os.Getenv(strings.TrimPrefix(name, "ENV_")))
+		}
+		this.warn(value.line, "%s cannot be expanded, it was left as is", expression)
+		return expression
+	})
+	if strings.Contains(supervisordExpression.ReplaceAllString(value.value, ""), "%(") {
+		this.warn(value.line, "%s contains a %%( that is not a supported expression, it was left as is", value.value)
+	}
+	return expanded
+}
+
+// Template formatting `field` of TemplateData like the Python `format`
+func supervisordTemplate(format, field string, quoted bool) string {
+	if format == "%s" || format == "%d" {
+		return fmt.Sprintf("{{.%s}}", field)
+	} else if quoted {
+		return fmt.Sprintf("'{{printf %q .%s}}'", format, field)
+	}
+	return fmt.Sprintf("{{printf %q .%s}}", format, field)
+}
+
+func (this *supervisordImporter) seconds(value iniValue, property string) (uint, error) {
+	seconds, err := strconv.ParseUint(value.value, 10, 0)
+	if err != nil {
+		return 0, newSupervisordError(value.line, fmt.Sprintf("invalid value for %s: %s", property, value.value))
+	}
+	return uint(seconds) * 1000, nil
+}
+
+// Get the stdout or stderr property of a task and its log file
+func (this *supervisordImporter) logfile(program string, value iniValue) (string, string) {
+	switch strings.ToUpper(value.value) {
+	case "NONE", "/DEV/NULL":
+		return "ignore", ""
+	case "AUTO":
+		return "redirect", ""
+	}
+	return "redirect", this.expand(program, value, false)
+}
+
+func (this *supervisordImporter) program(section iniSection) (ImportedTask, error) {
+	name, _ := strings.CutPrefix(section.name, "program:")
+	// Supervisord's defaults, which differ from taskmaster's
+	task := ImportedTask{
+		Name:               regexp.MustCompile(`[^a-zA-Z0-9_-]`).ReplaceAllString(name, "-"),
+		StartAtLaunch:      true,
+		Instances:          1,
+		Restart:            "on-failure",
+		RestartAttempts:    3,
+		ExpectedExitStatus: 0,
+		StartTime:          1000,
+		StopTime:           10000,
+		StopSignal:         "SIGTERM",
+		Stdout:             "redirect",
+		Stderr:             "redirect",
+	}
+	if task.Name != name {
+		this.warn(section.line, "program %s was renamed %s, task names must match ^[a-zA-Z0-9_-]+$", name, task.Name)
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(section.values)) {
+		if !slices.Contains(SUPERVISORD_PROGRAM_KEYS, key) {
+			this.warn(section.values[key].line, "%s is not supported, it was ignored", key)
+		}
+	}
+
+	command, ok := section.values["command"]
+	if !ok {
+		return task, newSupervisordError(section.line, fmt.Sprintf("missing command for program %s", name))
+	}
+	if words, err := splitCommand(this.expand(name, command, true)); err != nil {
+		return task, newSupervisordError(command.line, err.Error())
+	} else if len(words) == 0 {
+		return task, newSupervisordError(command.line, fmt.Sprintf("empty command for program %s", name))
+	} else {
+		task.Command, task.Arguments = words[0], words[1:]
+	}
+
+	if value, ok := section.values["numprocs"]; ok {
+		if instances, err := strconv.ParseUint(value.value, 10, 0); err != nil || instances == 0 {
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for numprocs: %s", value.value))
+		} else {
+			task.Instances = uint(instances)
+		}
+	}
+
+	if value, ok := section.values["autostart"]; ok {
+		if autostart, err := strconv.ParseBool(value.value); err != nil {
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for autostart: %s", value.value))
+		} else {
+			task.StartAtLaunch = autostart
+		}
+	}
+
+	if value, ok := section.values["autorestart"]; ok {
+		switch strings.ToLower(value.value) {
+		case "true":
+			task.Restart = "always"
+		case "false":
+			task.Restart = "never"
+		case "unexpected":
+			task.Restart = "on-failure"
+		default:
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for autorestart: %s", value.value))
+		}
+	}
+
+	if value, ok := section.values["startsecs"]; ok {
+		if startTime, err := this.seconds(value, "startsecs"); err != nil {
+			return task, err
+		} else {
+			task.StartTime = startTime
+		}
+	}
+
+	if value, ok := section.values["startretries"]; ok {
+		if retries, err := strconv.ParseUint(value.value, 10, 0); err != nil {
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for startretries: %s", value.value))
+		} else if retries == 0 {
+			this.warn(value.line, "startretries=0 has no equivalent (0 restart attempts means no limit), 1 was used instead")
+			task.RestartAttempts = 1
+		} else {
+			task.RestartAttempts = uint(retries)
+		}
+	}
+
+	if value, ok := section.values["exitcodes"]; ok {
+		codes := strings.Split(value.value, ",")
+		if code, err := strconv.Atoi(strings.TrimSpace(codes[0])); err != nil {
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for exitcodes: %s", value.value))
+		} else {
+			task.ExpectedExitStatus = code
+		}
+		if len(codes) > 1 {
+			this.warn(value.line, "only one exit code can be expected, %d was used", task.ExpectedExitStatus)
+		}
+	}
+
+	if value, ok := section.values["stopsignal"]; ok {
+		signal := "SIG" + strings.TrimPrefix(strings.ToUpper(value.value), "SIG")
+		if supported := []string{"SIGINT", "SIGQUIT", "SIGTERM", "SIGUSR1", "SIGUSR2", "SIGSTOP", "SIGTSTP"}; slices.Contains(supported, signal) {
+			task.StopSignal = signal
+		} else {
+			this.warn(value.line, "stopsignal %s is not supported, SIGTERM was used", value.value)
+		}
+	}
+
+	if value, ok := section.values["stopwaitsecs"]; ok {
+		if stopTime, err := this.seconds(value, "stopwaitsecs"); err != nil {
+			return task, err
+		} else {
+			task.StopTime = stopTime
+		}
+	}
+
+	if value, ok := section.values["stdout_logfile"]; ok {
+		task.Stdout, task.StdoutLogFile = this.logfile(name, value)
+	}
+	if value, ok := section.values["stderr_logfile"]; ok {
+		task.Stderr, task.StderrLogFile = this.logfile(name, value)
+	}
+
+	if value, ok := section.values["environment"]; ok {
+		if environment, err := parseEnvironment(this.expand(name, value, false)); err != nil {
+			return task, newSupervisordError(value.line, err.Error())
+		} else {
+			task.Environment = environment
+		}
+	}
+
+	if value, ok := section.values["directory"]; ok {
+		task.WorkingDirectory = this.expand(name, value, false)
+	}
+
+	if value, ok := section.values["umask"]; ok {
+		if _, err := strconv.ParseUint(value.value, 8, 0); err != nil {
+			return task, newSupervisordError(value.line, fmt.Sprintf("invalid value for umask: %s", value.value))
+		} else {
+			// Permissions are octal digits written as a decimal number
+			permissions, _ := strconv.ParseUint(value.value, 10, 0)
+			task.Permissions = utils.New(uint(permissions))
+		}
+	}
+
+	return task, nil
+}
+
+// Convert the [program:x] sections of a supervisord configuration file
+// into tasks. Settings that cannot be converted exactly are reported as
+// warnings.
+func ImportSupervisord(path string) (*ImportedConfig, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	sections, err := parseINI(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	here, _ := filepath.Abs(filepath.Dir(path))
+	importer := &supervisordImporter{here: here, warnings: []string{}}
+	result := &ImportedConfig{Tasks: []ImportedTask{}}
+
+	for _, section := range sections {
+		kind, _, _ := strings.Cut(section.name, ":")
+		switch {
+		case kind == "program":
+			if task, err := importer.program(section); err != nil {
+				return nil, nil, err
+			} else {
+				result.Tasks = append(result.Tasks, task)
+			}
+		case section.name == "unix_http_server":
+			if file, ok := section.values["file"]; ok {
+				result.Socket = importer.expand("", file, false)
+			}
+		case slices.Contains(SUPERVISORD_IGNORED_SECTIONS, section.name) || strings.HasPrefix(section.name, "rpcinterface:"):
+		default:
+			importer.warn(section.line, "[%s] sections are not supported, it was ignored", section.name)
+		}
+	}
+	return result, importer.warnings, nil
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/user"
+	"reflect"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Values available in the templates of the command, arguments, working
+// directory, environment and log files of a task, e.g. "{{.Instance}}".
+// Instances are numbered from 0.
+type TemplateData struct {
+	TaskName      string
+	Instance      uint
+	InstanceCount uint
+	LogDir        string
+	Hostname      string
+	User          string
+	Home          string
+}
+
+func NewTemplateData(config *Config, task *Task, instance uint) TemplateData {
+	data := TemplateData{
+		TaskName:      *task.Name,
+		Instance:      instance,
+		InstanceCount: task.Instances,
+		LogDir:        config.LogDir,
+	}
+	data.Hostname, _ = os.Hostname()
+	if current, err := user.Current(); err == nil {
+		data.User, data.Home = current.Username, current.HomeDir
+	}
+	return data
+}
+
+func templateInt(value any) (int, error) {
+	switch value := value.(type) {
+	case int:
+		return value, nil
+	case uint:
+		return int(value), nil
+	}
+	return 0, fmt.Errorf("expected an integer, got %v", value)
+}
+
+// Arithmetic on integers, e.g. "{{add 8000 .Instance}}"
+func templateArithmetic(operation func(int, int) int) func(any, any) (int, error) {
+	return func(left, right any) (int, error) {
+		leftInt, err := templateInt(left)
+		if err != nil {
+			return 0, err
+		}
+		rightInt, err := templateInt(right)
+		if err != nil {
+			return 0, err
+		}
+		return operation(leftInt, rightInt), nil
+	}
+}
+
+var TEMPLATE_FUNCTIONS = template.FuncMap{
+	"add": templateArithmetic(func(a, b int) int { return a + b }),
+	"sub": templateArithmetic(func(a, b int) int { return a - b }),
+	"mul": templateArithmetic(func(a, b int) int { return a * b }),
+}
+
+func isTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+func renderTemplate(property, text string, data TemplateData) (string, error) {
+	if !isTemplate(text) {
+		return text, nil
+	}
+	parsed, err := template.New(property).Funcs(TEMPLATE_FUNCTIONS).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", newTaskInvalidPropertyError(property, text, err.Error())
+	}
+	result := new(strings.Builder)
+	if err := parsed.Execute(result, data); err != nil {
+		return "", newTaskInvalidPropertyError(property, text, err.Error())
+	}
+	return result.String(), nil
+}
+
+// Get a copy of the task with its templates rendered with `data`
+func (this *Task) Render(data TemplateData) (Task, error) {
+	task := *this
+	task.Arguments = slices.Clone(this.Arguments)
+	task.Environment = maps.Clone(this.Environment)
+
+	command, err := renderTemplate("command", *this.Command, data)
+	if err != nil {
+		return task, err
+	}
+	task.Command = &command
+
+	for i, argument := range task.Arguments {
+		if task.Arguments[i], err = renderTemplate("arguments", argument, data); err != nil {
+			return task, err
+		}
+	}
+
+	// Only the values of the inline environment are templates, not the
+	// values from environment files
+	for _, key := range this.templatedEnvironment {
+		if task.Environment[key], err = renderTemplate("environment", task.Environment[key], data); err != nil {
+			return task, err
+		}
+	}
+
+	if task.WorkingDirectory, err = renderTemplate("workingDirectory", this.WorkingDirectory, data); err != nil {
+		return task, err
+	}
+	if task.StdoutLogFile, err = renderTemplate("stdoutLogFile", this.StdoutLogFile, data); err != nil {
+		return task, err
+	}
+	if task.StderrLogFile, err = renderTemplate("stderrLogFile", this.StderrLogFile, data); err != nil {
+		return task, err
+	}
+	return task, nil
+}
+
+// Whether the templates of the task render differently with `count` and
+// `otherCount` instances, like the ones using {{.InstanceCount}}
+func (this *Task) dependsOnInstanceCount(count, otherCount uint) bool {
+	data := TemplateData{TaskName: *this.Name, InstanceCount: count}
+	rendered, err := this.Render(data)
+	data.InstanceCount = otherCount
+	otherRendered, otherErr := this.Render(data)
+	return err != nil || otherErr != nil || !reflect.DeepEqual(rendered, otherRendered)
+}
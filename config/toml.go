@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Convert a TOML document to JSON, so that it goes through the same
+// defaults and validation as JSON files. The decoded document is kept to
+// find where errors come from.
+func tomlToJSON(data []byte) (any, []byte, error) {
+	var decoded map[string]any
+	if _, err := toml.Decode(string(data), &decoded); err != nil {
+		return nil, nil, err
+	}
+	result, err := json.Marshal(decoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Arrays of tables are decoded as []map[string]any, get back to the
+	// same types as the other formats
+	var document any
+	err = json.Unmarshal(result, &document)
+	return document, result, err
+}
+
+// Get the name of the table a TOML line is the header of, if it is one
+func tomlTableHeader(line string) (name string, isArray bool, ok bool) {
+	line = strings.TrimSpace(line)
+	if name, ok := strings.CutPrefix(line, "[["); ok {
+		name, _, _ = strings.Cut(name, "]]")
+		return strings.TrimSpace(name), true, true
+	}
+	if name, ok := strings.CutPrefix(line, "["); ok {
+		name, _, _ = strings.Cut(name, "]")
+		return strings.TrimSpace(name), false, true
+	}
+	return "", false, false
+}
+
+// Whether a TOML line sets the value of `key`
+func isTOMLKey(line, key string) bool {
+	name, _, ok := strings.Cut(line, "=")
+	return ok && len(key) != 0 && strings.EqualFold(strings.Trim(strings.TrimSpace(name), `"'`), key)
+}
+
+// Find the line of `key` in the table `index` of the [[tasks]] array of
+// tables (or in the root table if `index` is -1), or of the table itself
+// if the key cannot be found.
+func tomlLine(data []byte, index int, key string) int {
+	table := -1
+	tableLine := 0
+	inTable := index == -1
+
+	for i, line := range strings.Split(string(data), "\n") {
+		if name, isArray, ok := tomlTableHeader(line); ok {
+			if isArray && name == "tasks" {
+				table++
+			}
+			// Sub-tables of a task (such as [tasks.environment]) are not
+			// part of the properties of the task
+			inTable = isArray && name == "tasks" && table == index
+			if inTable {
+				tableLine = i + 1
+			}
+			continue
+		}
+		if inTable && isTOMLKey(line, key) {
+			return i + 1
+		}
+	}
+	return tableLine
+}
+
+// Prefix `err` with the line it comes from in the TOML document, when it
+// can be found.
+func locateTOMLError(data []byte, document any, err error) string {
+	line := 0
+	if index, taskErr := findInvalidTask(document); index != -1 {
+		line = tomlLine(data, index, errorProperty(taskErr))
+		err = taskErr
+	} else {
+		line = tomlLine(data, -1, errorProperty(err))
+	}
+
+	if line == 0 {
+		return err.Error()
+	}
+	return fmt.Sprintf("line %d: %s", line, err)
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Changes of a watched directory that may change the configuration. Whole
+// directories are watched, for the files replaced by renaming them (as most
+// editors do) and the new included files to be noticed.
+const WATCH_EVENTS = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM
+
+// Watcher of the configuration file, of the files it includes and of the
+// environment files of its tasks
+type Watcher struct {
+	path string
+	// Read through the runtime poller, for Close to interrupt the reads
+	file *os.File
+	fd   int
+	// Watched directories, by watch descriptor
+	directories map[int32]string
+	// Absolute globs of the included files
+	patterns []string
+	// Absolute paths of the environment files
+	environmentFiles []string
+	lock             *sync.Mutex
+	timer            *time.Timer
+}
+
+// Watch the configuration file at `path`, the files matching its include
+// globs and the environment files of its tasks with inotify, calling
+// `changed` once they have not changed for `delay`. The watched files follow
+// the configuration of `manager` when it is reloaded.
+func Watch(path string, manager Manager, delay time.Duration, changed func()) (*Watcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	instance := &Watcher{
+		path:        path,
+		file:        os.NewFile(uintptr(fd), "inotify"),
+		fd:          fd,
+		directories: map[int32]string{},
+		patterns:    []string{},
+		lock:        new(sync.Mutex),
+		timer:       time.AfterFunc(delay, changed),
+	}
+	instance.timer.Stop()
+	if err := instance.update(manager.Get()); err != nil {
+		instance.Close()
+		return nil, err
+	}
+	manager.Subscribe(func(_, config *Config) error {
+		instance.update(config)
+		return nil
+	})
+
+	go instance.run(delay)
+	return instance, nil
+}
+
+// Watch the directories the files of `config` may be in
+func (this *Watcher) update(config *Config) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	directories := []string{filepath.Dir(this.path)}
+	this.patterns = []string{}
+	for _, pattern := range config.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(this.path), pattern)
+		}
+		this.patterns = append(this.patterns, pattern)
+		// Directories created later are not watched when the directory
+		// part of the glob is a glob itself
+		matches, _ := filepath.Glob(filepath.Dir(pattern))
+		directories = append(directories, matches...)
+	}
+	this.environmentFiles = []string{}
+	for _, task := range config.Tasks {
+		for _, path := range task.EnvironmentFiles {
+			if path, err := filepath.Abs(path); err == nil && !slices.Contains(this.environmentFiles, path) {
+				this.environmentFiles = append(this.environmentFiles, path)
+				directories = append(directories, filepath.Dir(path))
+			}
+		}
+	}
+
+	for _, directory := range directories {
+		wd, err := syscall.InotifyAddWatch(this.fd, directory, WATCH_EVENTS)
+		if errors.Is(err, syscall.ENOENT) || errors.Is(err, syscall.ENOTDIR) {
+			continue
+		} else if err != nil {
+			return os.NewSyscallError("inotify_add_watch", err)
+		}
+		this.directories[int32(wd)] = directory
+	}
+	return nil
+}
+
+// Whether the file at `path` is the configuration file, one of the files
+// it includes or one of its environment files
+func (this *Watcher) matches(path string) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if path == this.path || slices.Contains(this.environmentFiles, path) {
+		return true
+	}
+	for _, pattern := range this.patterns {
+		if matched, _ := filepath.Match(pattern, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *Watcher) run(delay time.Duration) {
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		count, err := this.file.Read(buffer)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= count; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			start := offset + syscall.SizeofInotifyEvent
+			offset = start + int(event.Len)
+			name := strings.TrimRight(string(buffer[start:offset]), "\x00")
+
+			this.lock.Lock()
+			directory, ok := this.directories[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(this.directories, event.Wd)
+			}
+			this.lock.Unlock()
+
+			// Editing a file usually changes it several times in a row, it
+			// is only reloaded once it stops changing
+			if ok && len(name) != 0 && this.matches(filepath.Join(directory, name)) {
+				this.timer.Reset(delay)
+			}
+		}
+	}
+}
+
+// Stop watching the configuration files
+func (this *Watcher) Close() error {
+	this.timer.Stop()
+	return this.file.Close()
+}
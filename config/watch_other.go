@@ -0,0 +1,20 @@
+//go:build !linux
+
+package config
+
+import (
+	"errors"
+	"time"
+)
+
+type Watcher struct{}
+
+// Watching the configuration files relies on inotify, only available on
+// Linux
+func Watch(path string, manager Manager, delay time.Duration, changed func()) (*Watcher, error) {
+	return nil, errors.New("watching the configuration files is only supported on Linux")
+}
+
+func (this *Watcher) Close() error {
+	return nil
+}
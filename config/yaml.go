@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Convert a YAML document to JSON, so that it goes through the same
+// defaults and validation as JSON files. The document root is kept to
+// find where errors come from.
+func yamlToJSON(data []byte) (*yaml.Node, []byte, error) {
+	root := new(yaml.Node)
+	if err := yaml.Unmarshal(data, root); err != nil {
+		return nil, nil, err
+	}
+	var value any
+	if err := root.Decode(&value); err != nil {
+		return nil, nil, err
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+	result, err := json.Marshal(value)
+	return root, result, err
+}
+
+// Get the node an alias (e.g. "*tasks") refers to, or `node` itself
+func yamlResolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// Get the value of `key` in the mapping `node`, matching keys the same way
+// as encoding/json does
+func yamlLookup(node *yaml.Node, key string) (keyNode, valueNode *yaml.Node) {
+	node = yamlResolve(node)
+	if node == nil {
+		return nil, nil
+	}
+	if node.Kind == yaml.DocumentNode && len(node.Content) != 0 {
+		node = yamlResolve(node.Content[0])
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i], yamlResolve(node.Content[i+1])
+		}
+	}
+	return nil, nil
+}
+
+// Prefix `err` with the line of the YAML node it comes from, when it can
+// be found.
+func locateYAMLError(root *yaml.Node, err error) string {
+	var document any
+	root.Decode(&document)
+	line := 0
+
+	if index, taskErr := findInvalidTask(document); index != -1 {
+		// The tasks may come from a merge key ("<<: *common") that is not
+		// followed, the error is then returned without its line
+		if _, tasks := yamlLookup(root, "tasks"); tasks != nil && tasks.Kind == yaml.SequenceNode && index < len(tasks.Content) {
+			taskNode := yamlResolve(tasks.Content[index])
+			line = taskNode.Line
+			if key, _ := yamlLookup(taskNode, errorProperty(taskErr)); key != nil {
+				line = key.Line
+			}
+		}
+		err = taskErr
+	} else if key, _ := yamlLookup(root, errorProperty(err)); key != nil {
+		line = key.Line
+	}
+
+	if line == 0 {
+		return err.Error()
+	}
+	return fmt.Sprintf("line %d: %s", line, err)
+}
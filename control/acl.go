@@ -0,0 +1,108 @@
+package control
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"slices"
+	"syscall"
+
+	"taskmaster/config"
+	"taskmaster/messages/master/input"
+)
+
+// Roles given by the ACL rules, from the least to the most privileged
+const (
+	ROLE_NONE      = ""
+	ROLE_READ_ONLY = "read-only"
+	ROLE_OPERATOR  = "operator"
+)
+
+var ROLES = []string{ROLE_NONE, ROLE_READ_ONLY, ROLE_OPERATOR}
+
+// Credentials of the process at the other end of a UNIX socket connection
+type PeerCredentials struct {
+	Pid int32
+	Uid uint32
+	Gid uint32
+}
+
+// Get the credentials of the peer of `conn` with SO_PEERCRED
+func GetPeerCredentials(conn net.Conn) (PeerCredentials, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return PeerCredentials{}, newServerError(fmt.Sprintf("cannot get the credentials of a %s connection", conn.LocalAddr().Network()))
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return PeerCredentials{}, newServerError(err.Error())
+	}
+
+	var ucred *syscall.Ucred
+	var ucredErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		ucred, ucredErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return PeerCredentials{}, newServerError(err.Error())
+	}
+	if ucredErr != nil {
+		return PeerCredentials{}, newServerError(ucredErr.Error())
+	}
+	return PeerCredentials{ucred.Pid, ucred.Uid, ucred.Gid}, nil
+}
+
+// Get the primary and supplementary groups of the peer
+func (this PeerCredentials) groups() []uint32 {
+	groups := []uint32{this.Gid}
+	if peer, err := user.LookupId(fmt.Sprint(this.Uid)); err == nil {
+		if ids, err := peer.GroupIds(); err == nil {
+			for _, id := range ids {
+				var gid uint32
+				if _, err := fmt.Sscan(id, &gid); err == nil {
+					groups = append(groups, gid)
+				}
+			}
+		}
+	}
+	return groups
+}
+
+// Get the most privileged role `rules` give to the peer. The user running
+// taskmaster and root are always operators, and nobody else is anything
+// without rules.
+func Role(rules []config.AclRule, peer PeerCredentials) string {
+	if peer.Uid == uint32(os.Getuid()) || peer.Uid == 0 {
+		return ROLE_OPERATOR
+	}
+
+	role := ROLE_NONE
+	groups := peer.groups()
+	for _, rule := range rules {
+		if (rule.Uid != nil && *rule.Uid == peer.Uid) || (rule.Gid != nil && slices.Contains(groups, *rule.Gid)) {
+			if slices.Index(ROLES, rule.Role) > slices.Index(ROLES, role) {
+				role = rule.Role
+			}
+		}
+	}
+	return role
+}
+
+// Whether `role` is enough to send `req`
+func Allows(role string, req input.Message) bool {
+	switch req.(type) {
+	case input.Status, input.PlanReload:
+		return role == ROLE_READ_ONLY || role == ROLE_OPERATOR
+	default:
+		return role == ROLE_OPERATOR
+	}
+}
+
+// Permissions of a socket file, letting other users connect only when
+// there are ACL rules to restrict them
+func SocketMode(rules []config.AclRule) os.FileMode {
+	if len(rules) == 0 {
+		return 0o600
+	}
+	return 0o666
+}
@@ -0,0 +1,49 @@
+package control
+
+import (
+	"os"
+	"testing"
+
+	"taskmaster/config"
+	"taskmaster/messages/master/input"
+	"taskmaster/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Ids no one is likely to use, so that the peer has no supplementary group
+const testUid, testGid = 4242424, 4343434
+
+func TestRoleWithoutRules(t *testing.T) {
+	require.Equal(t, ROLE_NONE, Role([]config.AclRule{}, PeerCredentials{Uid: testUid, Gid: testGid}))
+	require.Equal(t, ROLE_OPERATOR, Role([]config.AclRule{}, PeerCredentials{Uid: uint32(os.Getuid())}))
+	require.Equal(t, ROLE_OPERATOR, Role([]config.AclRule{}, PeerCredentials{Uid: 0}))
+}
+
+func TestRoleOwner(t *testing.T) {
+	rules := []config.AclRule{{Uid: utils.New(uint32(testUid)), Role: ROLE_READ_ONLY}}
+
+	require.Equal(t, ROLE_OPERATOR, Role(rules, PeerCredentials{Uid: uint32(os.Getuid())}))
+}
+
+func TestRoleMostPrivileged(t *testing.T) {
+	rules := []config.AclRule{
+		{Uid: utils.New(uint32(testUid)), Role: ROLE_READ_ONLY},
+		{Gid: utils.New(uint32(testGid)), Role: ROLE_OPERATOR},
+		{Uid: utils.New(uint32(testUid + 1)), Role: ROLE_OPERATOR},
+	}
+
+	require.Equal(t, ROLE_READ_ONLY, Role(rules, PeerCredentials{Uid: testUid, Gid: testGid + 1}))
+	require.Equal(t, ROLE_OPERATOR, Role(rules, PeerCredentials{Uid: testUid, Gid: testGid}))
+	require.Equal(t, ROLE_NONE, Role(rules, PeerCredentials{Uid: testUid + 2, Gid: testGid + 1}))
+}
+
+func TestAllows(t *testing.T) {
+	require.True(t, Allows(ROLE_READ_ONLY, input.NewStatus()))
+	require.False(t, Allows(ROLE_READ_ONLY, input.NewRestartProcess(0, 0)))
+	require.False(t, Allows(ROLE_READ_ONLY, input.NewShutdown()))
+	require.True(t, Allows(ROLE_OPERATOR, input.NewReload()))
+	require.True(t, Allows(ROLE_READ_ONLY, input.NewPlanReload()))
+	require.False(t, Allows(ROLE_READ_ONLY, input.NewReload()))
+	require.False(t, Allows(ROLE_NONE, input.NewStatus()))
+}
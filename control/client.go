@@ -0,0 +1,44 @@
+package control
+
+import (
+	"bufio"
+	"net"
+
+	"taskmaster/messages/codec"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+)
+
+// Connection to the control socket of a running daemon
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, newServerError(err.Error())
+	}
+	return &Client{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Send `req` to the daemon and wait for its response
+func (this *Client) Request(req input.Message) (output.Message, error) {
+	data, err := codec.EncodeInput(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := this.conn.Write(append(data, '\n')); err != nil {
+		return nil, newServerError(err.Error())
+	}
+	line, err := this.reader.ReadBytes('\n')
+	if err != nil {
+		return nil, newServerError(err.Error())
+	}
+	return codec.DecodeOutput(line)
+}
+
+func (this *Client) Close() error {
+	return this.conn.Close()
+}
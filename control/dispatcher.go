@@ -0,0 +1,198 @@
+package control
+
+import (
+	"sync"
+
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+)
+
+// Share the input and output channels of a single MasterRunner between
+// several clients (interactive shell, control socket connections, ...),
+// routing each response back to the client that sent the matching request.
+type Dispatcher struct {
+	master chan<- input.Message
+
+	lock    *sync.Mutex
+	pending []*pendingRequest
+
+	done chan struct{}
+}
+
+type pendingRequest struct {
+	match    func(output.Message) bool
+	response chan output.Message
+}
+
+type taskDesignation interface {
+	TaskId() uint
+	TaskName() string
+}
+
+// Whether `res` is about the task designated by `req`, by name if it has
+// one, or else by the id it used even if a reload changed it meanwhile
+func sameTask(req, res taskDesignation) bool {
+	if len(req.TaskName()) != 0 {
+		return res.TaskName() == req.TaskName()
+	}
+	return res.TaskId() == req.TaskId()
+}
+
+// Return a function telling whether `res` answers `req`
+func responseMatcher(req input.Message) func(output.Message) bool {
+	switch req.(type) {
+
+	case input.Status:
+		return func(res output.Message) bool {
+			_, ok := res.(output.Status)
+			return ok
+		}
+
+	case input.StartProcess:
+		req := req.(input.StartProcess)
+		return func(msg output.Message) bool {
+			res, ok := msg.(output.StartProcess)
+			return ok && sameTask(req, res) && res.ProcessId() == req.ProcessId()
+		}
+
+	case input.StopProcess:
+		req := req.(input.StopProcess)
+		return func(msg output.Message) bool {
+			res, ok := msg.(output.StopProcess)
+			return ok && sameTask(req, res) && res.ProcessId() == req.ProcessId()
+		}
+
+	case input.RestartProcess:
+		req := req.(input.RestartProcess)
+		return func(msg output.Message) bool {
+			res, ok := msg.(output.RestartProcess)
+			return ok && sameTask(req, res) && res.ProcessId() == req.ProcessId()
+		}
+
+	case input.Reload:
+		return func(res output.Message) bool {
+			_, ok := res.(output.Reload)
+			return ok
+		}
+
+	case input.PlanReload:
+		return func(res output.Message) bool {
+			_, ok := res.(output.PlanReload)
+			return ok
+		}
+
+	case input.Shutdown:
+		return func(res output.Message) bool {
+			_, ok := res.(output.Shutdown)
+			return ok
+		}
+	}
+
+	return func(res output.Message) bool {
+		_, ok := res.(output.BadRequest)
+		return ok
+	}
+}
+
+func (this *Dispatcher) dispatch(res output.Message) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for i, req := range this.pending {
+		if req.match(res) {
+			this.pending = append(this.pending[:i], this.pending[i+1:]...)
+			req.response <- res
+			return
+		}
+	}
+	// Nobody asked for this response (e.g. reload triggered by SIGHUP)
+}
+
+func (this *Dispatcher) run(in <-chan output.Message) {
+	for res := range in {
+		this.dispatch(res)
+	}
+	this.lock.Lock()
+	for _, req := range this.pending {
+		close(req.response)
+	}
+	this.pending = nil
+	close(this.done)
+	this.lock.Unlock()
+}
+
+// Send `req` to the master and wait for its response.
+// `ok` is false if the master stopped before answering.
+func (this *Dispatcher) Request(req input.Message) (res output.Message, ok bool) {
+	pending := &pendingRequest{match: responseMatcher(req), response: make(chan output.Message, 1)}
+
+	this.lock.Lock()
+	select {
+	case <-this.done:
+		this.lock.Unlock()
+		return nil, false
+	default:
+	}
+	this.pending = append(this.pending, pending)
+	this.lock.Unlock()
+
+	select {
+	case this.master <- req:
+	case <-this.done:
+		return nil, false
+	}
+
+	res, ok = <-pending.response
+	return res, ok
+}
+
+// Create a pair of channels behaving like the ones of the master, for
+// clients (like the interactive shell) that work with channels.
+// Closing `out` detaches the session without stopping the master, and
+// `in` is closed once the master is closed.
+func (this *Dispatcher) NewSession() (in <-chan output.Message, out chan<- input.Message) {
+	responses := make(chan output.Message)
+	requests := make(chan input.Message)
+
+	go func() {
+		var inFlight sync.WaitGroup
+		defer func() {
+			inFlight.Wait()
+			close(responses)
+		}()
+		for {
+			select {
+			case req, ok := <-requests:
+				if !ok {
+					return
+				}
+				inFlight.Add(1)
+				go func() {
+					defer inFlight.Done()
+					if res, ok := this.Request(req); ok {
+						responses <- res
+					}
+				}()
+			case <-this.done:
+				return
+			}
+		}
+	}()
+
+	return responses, requests
+}
+
+// Channel closed once the master has closed its output
+func (this *Dispatcher) Done() <-chan struct{} {
+	return this.done
+}
+
+func NewDispatcher(out chan<- input.Message, in <-chan output.Message) *Dispatcher {
+	instance := &Dispatcher{
+		master:  out,
+		lock:    new(sync.Mutex),
+		pending: []*pendingRequest{},
+		done:    make(chan struct{}),
+	}
+	go instance.run(in)
+	return instance
+}
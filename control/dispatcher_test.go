@@ -0,0 +1,129 @@
+package control
+
+import (
+	"testing"
+	"time"
+
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Stand-in for the master, whose requests are answered by the test
+func newTestDispatcher(t *testing.T) (*Dispatcher, <-chan input.Message, chan<- output.Message) {
+	requests, responses := make(chan input.Message), make(chan output.Message)
+	return NewDispatcher(requests, responses), requests, responses
+}
+
+func TestDispatcherRoutesResponses(t *testing.T) {
+	dispatcher, requests, responses := newTestDispatcher(t)
+	defer close(responses)
+
+	first, firstOut := dispatcher.NewSession()
+	second, secondOut := dispatcher.NewSession()
+	defer close(firstOut)
+	defer close(secondOut)
+	firstOut <- input.NewStartProcess(0, 0)
+	secondOut <- input.NewStartProcess(1, 0)
+	<-requests
+	<-requests
+
+	// Answered in another order than asked, and with a response nobody
+	// asked for in between
+	responses <- output.NewStartProcessSuccess(1, "", 0)
+	responses <- output.NewReloadSuccess()
+	responses <- output.NewStartProcessFailure(0, "", 0, "process is already running")
+
+	res := (<-first).(output.StartProcess)
+	require.Equal(t, uint(0), res.TaskId())
+	require.IsType(t, output.NewStartProcessFailure(0, "", 0, ""), res)
+	res = (<-second).(output.StartProcess)
+	require.Equal(t, uint(1), res.TaskId())
+	require.IsType(t, output.NewStartProcessSuccess(0, "", 0), res)
+
+	// The unsolicited response was dropped rather than given to a later
+	// request
+	secondOut <- input.NewReload()
+	<-requests
+	responses <- output.NewReloadFailure("No task to run")
+	require.IsType(t, output.NewReloadFailure(""), <-second)
+}
+
+func TestDispatcherMatchesTaskNames(t *testing.T) {
+	dispatcher, requests, responses := newTestDispatcher(t)
+	defer close(responses)
+
+	byName := make(chan output.Message)
+	go func() {
+		res, _ := dispatcher.Request(input.NewStopProcessByName("web", 0))
+		byName <- res
+	}()
+	<-requests
+	byId := make(chan output.Message)
+	go func() {
+		res, _ := dispatcher.Request(input.NewStopProcess(1, 0))
+		byId <- res
+	}()
+	<-requests
+
+	// The request by id was sent to "worker" before a reload moved "web"
+	// to its id, both responses have the same id
+	responses <- output.NewStopProcessSuccess(1, "worker", 0)
+	responses <- output.NewStopProcessSuccess(1, "web", 0)
+	require.Equal(t, "web", (<-byName).(output.StopProcess).TaskName())
+	require.Equal(t, "worker", (<-byId).(output.StopProcess).TaskName())
+}
+
+func TestDispatcherConcurrentRequests(t *testing.T) {
+	dispatcher, requests, responses := newTestDispatcher(t)
+	defer close(responses)
+	go func() {
+		for req := range requests {
+			req := req.(input.StopProcess)
+			go func() {
+				responses <- output.NewStopProcessSuccess(req.TaskId(), req.TaskName(), req.ProcessId())
+			}()
+		}
+	}()
+
+	results := make(chan bool)
+	for i := range uint(20) {
+		go func() {
+			res, ok := dispatcher.Request(input.NewStopProcess(i, i%3))
+			stop, isStop := res.(output.StopProcess)
+			results <- ok && isStop && stop.TaskId() == i && stop.ProcessId() == i%3
+		}()
+	}
+	for range 20 {
+		require.True(t, <-results)
+	}
+}
+
+func TestDispatcherMasterClosed(t *testing.T) {
+	dispatcher, requests, responses := newTestDispatcher(t)
+	session, sessionOut := dispatcher.NewSession()
+
+	// Waiting for a response
+	pending := make(chan bool)
+	go func() {
+		_, ok := dispatcher.Request(input.NewStatus())
+		pending <- ok
+	}()
+	<-requests
+	close(responses)
+	require.False(t, <-pending)
+
+	select {
+	case <-dispatcher.Done():
+	case <-time.After(time.Second):
+		require.Fail(t, "the dispatcher is not done")
+	}
+	_, ok := dispatcher.Request(input.NewStatus())
+	require.False(t, ok)
+
+	// Sessions are closed without being detached
+	_, ok = <-session
+	require.False(t, ok)
+	close(sessionOut)
+}
@@ -0,0 +1,161 @@
+package control
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/messages/codec"
+	"taskmaster/messages/master/output"
+)
+
+// UNIX socket server accepting newline delimited requests, and answering
+// each of them with a newline delimited response, both encoded with the
+// codec package.
+type Server struct {
+	Path string
+
+	dispatcher  *Dispatcher
+	manager     config.Manager
+	listener    net.Listener
+	connections *sync.WaitGroup
+	lock        *sync.Mutex
+	open        map[net.Conn]struct{}
+}
+
+type ServerError struct {
+	cause string
+}
+
+func (this ServerError) Error() string {
+	return fmt.Sprintf("Control socket error: %s", this.cause)
+}
+
+func newServerError(cause string) ServerError {
+	return ServerError{cause}
+}
+
+// Remove the socket file at `path` if no server is listening on it anymore.
+// Anything else than a socket is left untouched and fails.
+func RemoveStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is already in use by another instance", path)
+	}
+	return os.Remove(path)
+}
+
+// Listen on the UNIX socket at `path`, authorizing the requests with the
+// ACL of the configuration of `manager`.
+func Listen(path string, dispatcher *Dispatcher, manager config.Manager) (*Server, error) {
+	if err := RemoveStaleSocket(path); err != nil {
+		return nil, newServerError(err.Error())
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, newServerError(err.Error())
+	}
+	if err := os.Chmod(path, SocketMode(manager.Get().Acl)); err != nil {
+		listener.Close()
+		return nil, newServerError(err.Error())
+	}
+	manager.Subscribe(func(_, conf *config.Config) error {
+		if err := os.Chmod(path, SocketMode(conf.Acl)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return newServerError(err.Error())
+		}
+		return nil
+	})
+	return &Server{
+		Path:        path,
+		dispatcher:  dispatcher,
+		manager:     manager,
+		listener:    listener,
+		connections: new(sync.WaitGroup),
+		lock:        new(sync.Mutex),
+		open:        map[net.Conn]struct{}{},
+	}, nil
+}
+
+func (this *Server) handle(conn net.Conn) {
+	defer this.connections.Done()
+	defer func() {
+		this.lock.Lock()
+		delete(this.open, conn)
+		this.lock.Unlock()
+		conn.Close()
+	}()
+
+	peer, err := GetPeerCredentials(conn)
+	if err != nil {
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var res output.Message
+
+		if req, err := codec.DecodeInput(scanner.Bytes()); err != nil {
+			res = output.NewBadRequest()
+		} else if !Allows(Role(this.manager.Get().Acl, peer), req) {
+			res = output.NewForbidden()
+		} else if response, ok := this.dispatcher.Request(req); !ok {
+			return
+		} else {
+			res = response
+		}
+
+		data, err := codec.EncodeOutput(res)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(append(data, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+// Accept connections until the server is closed
+func (this *Server) Serve() error {
+	for {
+		conn, err := this.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return newServerError(err.Error())
+		}
+		this.lock.Lock()
+		this.open[conn] = struct{}{}
+		this.lock.Unlock()
+		this.connections.Add(1)
+		go this.handle(conn)
+	}
+}
+
+// Stop accepting connections, wait for the pending requests to be
+// answered, and remove the socket file.
+func (this *Server) Close() error {
+	err := this.listener.Close()
+	this.lock.Lock()
+	for conn := range this.open {
+		// Interrupt connections waiting for a request
+		conn.SetReadDeadline(time.Now())
+	}
+	this.lock.Unlock()
+	this.connections.Wait()
+	return err
+}
@@ -0,0 +1,113 @@
+package control
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taskmaster/atom"
+	"taskmaster/config"
+	"taskmaster/messages/codec"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+	processOutput "taskmaster/messages/process/output"
+	taskOutput "taskmaster/messages/task/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testManager struct {
+	config *config.Config
+}
+
+func (this testManager) Get() *config.Config                               { return this.config }
+func (this testManager) Subscribe(atom.AtomSubscriberFunc[*config.Config]) {}
+func (this testManager) Load() error                                       { return nil }
+func (this testManager) Plan() (config.ReloadPlan, error)                  { return config.ReloadPlan{}, nil }
+
+func TestServerRoundTrip(t *testing.T) {
+	dispatcher, requests, responses := newTestDispatcher(t)
+	defer close(responses)
+	go func() {
+		for req := range requests {
+			switch req.(type) {
+			case input.Status:
+				responses <- output.NewStatus([]taskOutput.Status{
+					taskOutput.NewStatus(0, "web", []processOutput.Status{processOutput.NewStatus(0, "RUNNING")}),
+				})
+			default:
+				responses <- output.NewBadRequest()
+			}
+		}
+	}()
+
+	path := filepath.Join(t.TempDir(), "taskmaster.sock")
+	server, err := Listen(path, dispatcher, testManager{&config.Config{}})
+	require.Nil(t, err)
+	go server.Serve()
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	client, err := Dial(path)
+	require.Nil(t, err)
+	res, err := client.Request(input.NewStatus())
+	require.Nil(t, err)
+	status := res.(output.Status)
+	require.Len(t, status.Tasks(), 1)
+	require.Equal(t, "web", status.Tasks()[0].Name())
+
+	// Requests that cannot be decoded are answered without reaching the
+	// master, and the connection stays usable
+	_, err = client.conn.Write([]byte("{\"type\": \"unknown\"}\n"))
+	require.Nil(t, err)
+	line, err := client.reader.ReadBytes('\n')
+	require.Nil(t, err)
+	res, err = codec.DecodeOutput(line)
+	require.Nil(t, err)
+	require.Implements(t, (*output.BadRequest)(nil), res)
+	res, err = client.Request(input.NewStatus())
+	require.Nil(t, err)
+	require.Implements(t, (*output.Status)(nil), res)
+
+	// Closing the server ends the idle connections and removes the socket
+	require.Nil(t, server.Close())
+	_, err = client.reader.ReadBytes('\n')
+	require.NotNil(t, err)
+	client.Close()
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, RemoveStaleSocket(filepath.Join(dir, "missing.sock")))
+
+	file := filepath.Join(dir, "file.sock")
+	require.Nil(t, os.WriteFile(file, []byte("data"), 0o600))
+	require.NotNil(t, RemoveStaleSocket(file))
+	require.FileExists(t, file)
+
+	live := filepath.Join(dir, "live.sock")
+	listener, err := net.Listen("unix", live)
+	require.Nil(t, err)
+	defer listener.Close()
+	err = RemoveStaleSocket(live)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "already in use")
+	_, err = os.Lstat(live)
+	require.Nil(t, err)
+
+	// Left behind by a server that did not remove it, e.g. killed
+	stale := filepath.Join(dir, "stale.sock")
+	staleListener, err := net.ListenUnix("unix", &net.UnixAddr{Name: stale, Net: "unix"})
+	require.Nil(t, err)
+	staleListener.SetUnlinkOnClose(false)
+	staleListener.Close()
+	_, err = os.Lstat(stale)
+	require.Nil(t, err)
+	require.Nil(t, RemoveStaleSocket(stale))
+	_, err = os.Lstat(stale)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"taskmaster/config"
+	"taskmaster/control"
+	"taskmaster/messages/master/input"
+)
+
+// Environment variable set on the detached child process
+const DAEMON_ENV = "TASKMASTER_DAEMON"
+
+// Start the current executable again in its own session, with its output
+// redirected to a log file, and return the pid of the new process.
+func detach(conf *config.Config) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(
+		fmt.Sprintf("%s/taskmasterd.log", conf.LogDir),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0o666,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	command := exec.Command(executable, os.Args[1:]...)
+	command.Env = append(os.Environ(), DAEMON_ENV+"=1")
+	command.Stdout = logFile
+	command.Stderr = logFile
+	command.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := command.Start(); err != nil {
+		return 0, err
+	}
+	pid := command.Process.Pid
+	return pid, command.Process.Release()
+}
+
+// Wait for the master to shut down, asking it to do so on SIGINT or SIGTERM
+func waitForShutdown(dispatcher *control.Dispatcher) {
+	stopSignal := make(chan os.Signal, 1)
+	signal.Notify(stopSignal, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stopSignal
+		dispatcher.Request(input.NewShutdown())
+	}()
+
+	<-dispatcher.Done()
+}
@@ -0,0 +1,196 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Number of events buffered for each subscriber. Events are dropped for
+// subscribers that do not keep up, so that publishers never block. See
+// SubscribeAll for subscribers that must not miss any.
+const SUBSCRIBER_BUFFER = 64
+
+// Fan out published events to every subscriber, and keep the most recent
+// ones so that clients can catch up on what they missed.
+type Broker struct {
+	lock        *sync.Mutex
+	subscribers map[chan Event]struct{}
+	queues      map[*queue]struct{}
+	history     []Event
+	historySize int
+	sequence    uint64
+	published   chan struct{}
+}
+
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		lock:        new(sync.Mutex),
+		subscribers: map[chan Event]struct{}{},
+		queues:      map[*queue]struct{}{},
+		history:     []Event{},
+		historySize: historySize,
+		published:   make(chan struct{}),
+	}
+}
+
+// Assign a sequence number and a timestamp to `event`, and send it to
+// every subscriber.
+func (this *Broker) Publish(event Event) Event {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.sequence++
+	event.Sequence = this.sequence
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	this.history = append(this.history, event)
+	if len(this.history) > this.historySize {
+		this.history = this.history[len(this.history)-this.historySize:]
+	}
+
+	for subscriber := range this.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+		}
+	}
+	for queue := range this.queues {
+		queue.push(event)
+	}
+	close(this.published)
+	this.published = make(chan struct{})
+	return event
+}
+
+// Receive every event published from now on, until `unsubscribe` is called
+func (this *Broker) Subscribe() (events <-chan Event, unsubscribe func()) {
+	subscriber := make(chan Event, SUBSCRIBER_BUFFER)
+
+	this.lock.Lock()
+	this.subscribers[subscriber] = struct{}{}
+	this.lock.Unlock()
+
+	once := new(sync.Once)
+	return subscriber, func() {
+		once.Do(func() {
+			this.lock.Lock()
+			delete(this.subscribers, subscriber)
+			close(subscriber)
+			this.lock.Unlock()
+		})
+	}
+}
+
+// Same as Subscribe, but events are queued instead of dropped when the
+// subscriber does not keep up, however many there are.
+func (this *Broker) SubscribeAll() (events <-chan Event, unsubscribe func()) {
+	subscriber := make(chan Event)
+	queue := newQueue()
+
+	this.lock.Lock()
+	this.queues[queue] = struct{}{}
+	this.lock.Unlock()
+
+	go queue.forward(subscriber)
+	once := new(sync.Once)
+	return subscriber, func() {
+		once.Do(func() {
+			this.lock.Lock()
+			delete(this.queues, queue)
+			this.lock.Unlock()
+			close(queue.done)
+		})
+	}
+}
+
+// Get the events still in history with a sequence number greater than `sequence`
+func (this *Broker) Since(sequence uint64) []Event {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.since(sequence)
+}
+
+func (this *Broker) since(sequence uint64) []Event {
+	result := []Event{}
+	for _, event := range this.history {
+		if event.Sequence > sequence {
+			result = append(result, event)
+		}
+	}
+	return result
+}
+
+// Same as Since, but wait for new events if there are none yet, until
+// `ctx` is done.
+func (this *Broker) Wait(ctx context.Context, sequence uint64) []Event {
+	for {
+		this.lock.Lock()
+		result := this.since(sequence)
+		published := this.published
+		this.lock.Unlock()
+
+		if len(result) != 0 {
+			return result
+		}
+		select {
+		case <-published:
+		case <-ctx.Done():
+			return result
+		}
+	}
+}
+
+// Events published for a subscriber of SubscribeAll, waiting to be received
+type queue struct {
+	lock   *sync.Mutex
+	events []Event
+	ready  chan struct{}
+	done   chan struct{}
+}
+
+func newQueue() *queue {
+	return &queue{
+		lock:   new(sync.Mutex),
+		events: []Event{},
+		ready:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+func (this *queue) push(event Event) {
+	this.lock.Lock()
+	this.events = append(this.events, event)
+	this.lock.Unlock()
+
+	select {
+	case this.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Send the queued events to `subscriber` in order, until `done` is closed
+func (this *queue) forward(subscriber chan<- Event) {
+	defer close(subscriber)
+	for {
+		this.lock.Lock()
+		pending := this.events
+		this.events = []Event{}
+		this.lock.Unlock()
+
+		for _, event := range pending {
+			select {
+			case subscriber <- event:
+			case <-this.done:
+				return
+			}
+		}
+		select {
+		case <-this.ready:
+		case <-this.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	broker := NewBroker(16)
+	stream, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
+	published := broker.Publish(Event{Type: "STARTED", Task: "web", Instance: 1})
+
+	event := <-stream
+	require.Equal(t, uint64(1), event.Sequence)
+	require.Equal(t, "STARTED", event.Type)
+	require.Equal(t, published.Time, event.Time)
+}
+
+func TestHistory(t *testing.T) {
+	broker := NewBroker(2)
+	for _, eventType := range []string{"STARTING", "STARTED", "STOPPING"} {
+		broker.Publish(Event{Type: eventType})
+	}
+
+	history := broker.Since(0)
+	require.Len(t, history, 2)
+	require.Equal(t, "STARTED", history[0].Type)
+	require.Equal(t, "STOPPING", history[1].Type)
+	require.Len(t, broker.Since(3), 0)
+}
+
+func TestWait(t *testing.T) {
+	broker := NewBroker(16)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		broker.Publish(Event{Type: "STOPPED_UNSUCCESSFULLY"})
+	}()
+
+	result := broker.Wait(context.Background(), 0)
+	require.Len(t, result, 1)
+	require.Equal(t, "STOPPED_UNSUCCESSFULLY", result[0].Type)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Len(t, broker.Wait(ctx, 1), 0)
+}
+
+func TestSubscribeAllKeepsEvents(t *testing.T) {
+	broker := NewBroker(16)
+	dropping, unsubscribeDropping := broker.Subscribe()
+	defer unsubscribeDropping()
+	stream, unsubscribe := broker.SubscribeAll()
+
+	for range 4 * SUBSCRIBER_BUFFER {
+		broker.Publish(Event{Type: "STARTED"})
+	}
+
+	require.Len(t, dropping, SUBSCRIBER_BUFFER)
+	for sequence := range uint64(4 * SUBSCRIBER_BUFFER) {
+		require.Equal(t, sequence+1, (<-stream).Sequence)
+	}
+
+	broker.Publish(Event{Type: "STOPPED"})
+	unsubscribe()
+	for range stream {
+	}
+}
@@ -0,0 +1,30 @@
+package events
+
+import "time"
+
+// Event types that are not process responses
+const (
+	RELOAD_SUCCEEDED = "RELOAD_SUCCEEDED"
+	RELOAD_FAILED    = "RELOAD_FAILED"
+	LOG              = "LOG"
+)
+
+// Something that happened to a process, or to the whole taskmaster
+// configuration for reload events (which have no task). Log events carry
+// a line written by a process on one of its output streams.
+type Event struct {
+	Sequence  uint64     `json:"sequence"`
+	Time      time.Time  `json:"time"`
+	Type      string     `json:"type"`
+	Task      string     `json:"task,omitempty"`
+	TaskId    uint       `json:"taskId"`
+	Instance  uint       `json:"instance"`
+	Pid       int        `json:"pid,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	ExitCode  *int       `json:"exitCode,omitempty"`
+	Requested bool       `json:"requested,omitempty"`
+	Retries   uint       `json:"retries"`
+	Error     string     `json:"error,omitempty"`
+	Stream    string     `json:"stream,omitempty"`
+	Text      string     `json:"text,omitempty"`
+}
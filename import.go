@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"taskmaster/config"
+)
+
+// Convert a supervisord configuration file to a taskmaster JSON
+// configuration, written on stdout (or to -output), with the settings that
+// could not be converted exactly reported on stderr.
+func importCommand(args []string) int {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	outputPath := flags.String("output", "", "path/to/taskmaster/configuration/file.json (default: stdout)")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import [-output file.json] supervisord.conf\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	imported, warnings, err := config.ImportSupervisord(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
+	data, err := json.MarshalIndent(imported, "", "  ")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	data = append(data, '\n')
+	if len(*outputPath) == 0 {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*outputPath, data, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package logs
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// Length from which a line not terminated yet is kept as a line of its
+// own, for the output without newlines (progress bars, binary data, ...)
+// not to be accumulated forever
+const MAX_LINE_LENGTH = 64 * 1024
+
+// A line written by a process on one of its output streams
+type Line struct {
+	Time   time.Time `json:"time"`
+	Stream string    `json:"stream"`
+	Text   string    `json:"text"`
+}
+
+// Keep the last lines written by a process, on all of its streams
+type Buffer struct {
+	lock     *sync.Mutex
+	lines    []Line
+	size     int
+	partials map[string][]byte
+	onLine   func(Line)
+}
+
+// Create a buffer keeping `size` lines, calling `onLine` (if not nil) for
+// every new line.
+func NewBuffer(size int, onLine func(Line)) *Buffer {
+	return &Buffer{
+		lock:     new(sync.Mutex),
+		lines:    []Line{},
+		size:     size,
+		partials: map[string][]byte{},
+		onLine:   onLine,
+	}
+}
+
+func (this *Buffer) append(line Line) {
+	if this.onLine != nil {
+		this.onLine(line)
+	}
+	this.lines = append(this.lines, line)
+	if len(this.lines) > this.size {
+		this.lines = this.lines[len(this.lines)-this.size:]
+	}
+}
+
+// Split `data` into lines tagged with `stream`. The last line is kept
+// aside until it is terminated by a later write, or split once it reaches
+// MAX_LINE_LENGTH.
+func (this *Buffer) write(stream string, data []byte) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	data = append(this.partials[stream], data...)
+	for {
+		end := bytes.IndexByte(data, '\n')
+		if end == -1 || end > MAX_LINE_LENGTH {
+			if len(data) < MAX_LINE_LENGTH {
+				break
+			}
+			this.append(Line{time.Now(), stream, string(data[:MAX_LINE_LENGTH])})
+			data = data[MAX_LINE_LENGTH:]
+			continue
+		}
+		this.append(Line{time.Now(), stream, string(data[:end])})
+		data = data[end+1:]
+	}
+	this.partials[stream] = bytes.Clone(data)
+}
+
+// Get the last `count` lines, or every line kept if `count` is 0
+func (this *Buffer) Lines(count int) []Line {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	lines := this.lines
+	if count != 0 && count < len(lines) {
+		lines = lines[len(lines)-count:]
+	}
+	return append([]Line{}, lines...)
+}
+
+type streamWriter struct {
+	buffer *Buffer
+	stream string
+}
+
+func (this streamWriter) Write(data []byte) (int, error) {
+	this.buffer.write(this.stream, data)
+	return len(data), nil
+}
+
+// Get a writer adding the lines written to it to the buffer, tagged with `stream`
+func (this *Buffer) Writer(stream string) io.Writer {
+	return streamWriter{this, stream}
+}
@@ -0,0 +1,61 @@
+package logs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func texts(lines []Line) []string {
+	result := []string{}
+	for _, line := range lines {
+		result = append(result, line.Stream+": "+line.Text)
+	}
+	return result
+}
+
+func TestBufferSplitLines(t *testing.T) {
+	buffer := NewBuffer(10, nil)
+	stdout := buffer.Writer("stdout")
+	stderr := buffer.Writer("stderr")
+
+	stdout.Write([]byte("hello\nwor"))
+	stderr.Write([]byte("oops\n"))
+	stdout.Write([]byte("ld\n"))
+
+	require.Equal(t, []string{"stdout: hello", "stderr: oops", "stdout: world"}, texts(buffer.Lines(0)))
+}
+
+func TestBufferKeepLastLines(t *testing.T) {
+	buffer := NewBuffer(2, nil)
+
+	buffer.Writer("stdout").Write([]byte("1\n2\n3\n"))
+
+	require.Equal(t, []string{"stdout: 2", "stdout: 3"}, texts(buffer.Lines(0)))
+	require.Equal(t, []string{"stdout: 3"}, texts(buffer.Lines(1)))
+}
+
+func TestBufferSplitLongLines(t *testing.T) {
+	buffer := NewBuffer(10, nil)
+	stdout := buffer.Writer("stdout")
+
+	progress := strings.Repeat("50%\r", MAX_LINE_LENGTH/4)
+	stdout.Write([]byte(progress[:1000]))
+	stdout.Write([]byte(progress[1000:] + "100%\n"))
+
+	lines := buffer.Lines(0)
+	require.Len(t, lines, 2)
+	require.Equal(t, progress, lines[0].Text)
+	require.Equal(t, "100%", lines[1].Text)
+	require.Empty(t, buffer.partials["stdout"])
+}
+
+func TestBufferOnLine(t *testing.T) {
+	lines := []Line{}
+	buffer := NewBuffer(1, func(line Line) { lines = append(lines, line) })
+
+	buffer.Writer("stderr").Write([]byte("1\n2\n3"))
+
+	require.Equal(t, []string{"stderr: 1", "stderr: 2"}, texts(lines))
+}
@@ -0,0 +1,43 @@
+package logs
+
+import "sync"
+
+type processKey struct {
+	task     string
+	instance uint
+}
+
+// Buffers of the recent output of every process, by task name and instance
+type Store struct {
+	lock    *sync.Mutex
+	buffers map[processKey]*Buffer
+	size    int
+	onLine  func(task string, instance uint, line Line)
+}
+
+// Create a store of buffers keeping `size` lines each, calling `onLine`
+// (if not nil) for every new line of every process.
+func NewStore(size int, onLine func(task string, instance uint, line Line)) *Store {
+	return &Store{
+		lock:    new(sync.Mutex),
+		buffers: map[processKey]*Buffer{},
+		size:    size,
+		onLine:  onLine,
+	}
+}
+
+// Get the buffer of an instance of `task`, creating it if needed
+func (this *Store) Get(task string, instance uint) *Buffer {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	key := processKey{task, instance}
+	if _, ok := this.buffers[key]; !ok {
+		var onLine func(Line)
+		if this.onLine != nil {
+			onLine = func(line Line) { this.onLine(task, instance, line) }
+		}
+		this.buffers[key] = NewBuffer(this.size, onLine)
+	}
+	return this.buffers[key]
+}
@@ -2,23 +2,69 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
+	"taskmaster/api"
 	"taskmaster/config"
+	"taskmaster/control"
 	"taskmaster/messages/master/input"
 	"taskmaster/messages/master/output"
+	"taskmaster/metrics"
+	"taskmaster/notifications"
 	"taskmaster/runners"
 	"taskmaster/shell"
+	"taskmaster/utils"
 )
 
+// Time without changes to the configuration files after which they are
+// reloaded
+const WATCH_DEBOUNCE = 500 * time.Millisecond
+
+type server interface {
+	Serve() error
+	Close() error
+}
+
 func main() {
 	configPath := flag.String("config", "./tmconfig.json", "path/to/taskmaster/configuration/file.json")
+	daemon := flag.Bool("daemon", false, "run in the background, controlled through the configured UNIX socket")
+	watch := flag.Bool("watch", false, "reload the configuration when its files change, as if it set watch to true")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config file] [-daemon] [-watch]\n       %s [-config file] check [-json] [file]\n       %s import [-output file.json] supervisord.conf\n       %s schema\n", os.Args[0], os.Args[0], os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
+	switch flag.Arg(0) {
+	case "":
+	case "check":
+		os.Exit(checkCommand(flag.Args()[1:], *configPath))
+	case "import":
+		os.Exit(importCommand(flag.Args()[1:]))
+	case "schema":
+		os.Exit(schemaCommand(flag.Args()[1:]))
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
 	configManager, err := config.NewManager(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to load config: %s\n", err)
 	}
+	conf := configManager.Get()
+
+	if *daemon && os.Getenv(DAEMON_ENV) == "" {
+		if pid, err := detach(conf); err != nil {
+			log.Fatalf("Failed to start daemon: %s", err)
+		} else {
+			log.Printf("Daemon started (pid %d), listening on %s", pid, conf.Socket)
+		}
+		return
+	}
 
 	req := make(chan input.Message)
 	res := make(chan output.Message)
@@ -26,6 +72,48 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to initialize runner: %s", err)
 	}
+	dispatcher := control.NewDispatcher(req, res)
+	collector := metrics.NewCollector(configManager, runners.Events)
+	notifications.NewNotifier(configManager, runners.Events, runners.Report)
+
+	servers := []server{}
+	if *daemon {
+		servers = append(servers, utils.Must(control.Listen(conf.Socket, dispatcher, configManager)))
+	}
+	if len(conf.Http) != 0 {
+		servers = append(servers, utils.Must(api.Listen(conf.Http, dispatcher, runners.Events, collector, runners.Logs, configManager)))
+	}
+	for _, server := range servers {
+		go func() {
+			if err := server.Serve(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+
+	// The files are always watched for the watch property to be enabled by
+	// a reload, the changes are ignored while it is not
+	watching := func() bool { return *watch || configManager.Get().Watch }
+	if watcher, err := config.Watch(*configPath, configManager, WATCH_DEBOUNCE, func() {
+		if watching() {
+			runner.Reload()
+		}
+	}); err != nil {
+		if watching() {
+			log.Printf("Failed to watch the configuration files: %s", err)
+		}
+	} else {
+		defer watcher.Close()
+	}
+
 	go runner.Run()
-	shell.StartShell(res, req)
+	if *daemon {
+		waitForShutdown(dispatcher)
+	} else {
+		shell.StartShell(dispatcher.NewSession())
+	}
+
+	for _, server := range servers {
+		server.Close()
+	}
 }
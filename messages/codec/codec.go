@@ -0,0 +1,81 @@
+// Versioned JSON encoding of the master messages, allowing the master to be
+// driven from outside of the process.
+//
+// Every message is wrapped in an envelope holding the version of the
+// encoding, a tag identifying the message type, and the message payload:
+//
+//	{"version":1,"type":"start-process","payload":{"taskId":0,"processId":1}}
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Current version of the encoding. Decoding a message encoded with a
+// greater version fails.
+const VERSION uint = 1
+
+// Message type tags
+const (
+	TYPE_STATUS          = "status"
+	TYPE_START_PROCESS   = "start-process"
+	TYPE_STOP_PROCESS    = "stop-process"
+	TYPE_RESTART_PROCESS = "restart-process"
+	TYPE_RELOAD          = "reload"
+	TYPE_PLAN_RELOAD     = "plan-reload"
+	TYPE_SHUTDOWN        = "shutdown"
+	TYPE_BAD_REQUEST     = "bad-request"
+	TYPE_FORBIDDEN       = "forbidden"
+)
+
+type Envelope struct {
+	Version uint            `json:"version"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type CodecError struct {
+	cause string
+}
+
+func (this CodecError) Error() string {
+	return fmt.Sprintf("Invalid message: %s", this.cause)
+}
+
+func newCodecError(cause string) CodecError {
+	return CodecError{cause}
+}
+
+func encode(messageType string, payload any) ([]byte, error) {
+	envelope := Envelope{Version: VERSION, Type: messageType}
+	if payload != nil {
+		if data, err := json.Marshal(payload); err != nil {
+			return nil, err
+		} else {
+			envelope.Payload = data
+		}
+	}
+	return json.Marshal(envelope)
+}
+
+func decodeEnvelope(data []byte) (*Envelope, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, newCodecError(err.Error())
+	}
+	if envelope.Version == 0 || envelope.Version > VERSION {
+		return nil, newCodecError(fmt.Sprintf("unsupported version %d (expected at most %d)", envelope.Version, VERSION))
+	}
+	return &envelope, nil
+}
+
+func decodePayload(envelope *Envelope, payload any) error {
+	if len(envelope.Payload) == 0 {
+		return newCodecError(fmt.Sprintf("missing payload for message type %s", envelope.Type))
+	}
+	if err := json.Unmarshal(envelope.Payload, payload); err != nil {
+		return newCodecError(err.Error())
+	}
+	return nil
+}
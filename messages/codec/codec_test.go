@@ -0,0 +1,138 @@
+package codec
+
+import (
+	"testing"
+
+	"taskmaster/config"
+	"taskmaster/messages/helpers"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+	processOutput "taskmaster/messages/process/output"
+	taskOutput "taskmaster/messages/task/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func roundTripInput(t *testing.T, msg input.Message) input.Message {
+	data, err := EncodeInput(msg)
+	require.Nil(t, err)
+	decoded, err := DecodeInput(data)
+	require.Nil(t, err)
+	return decoded
+}
+
+func roundTripOutput(t *testing.T, msg output.Message) output.Message {
+	data, err := EncodeOutput(msg)
+	require.Nil(t, err)
+	decoded, err := DecodeOutput(data)
+	require.Nil(t, err)
+	return decoded
+}
+
+func TestEncodeInputStartProcess(t *testing.T) {
+	data, err := EncodeInput(input.NewStartProcess(2, 3))
+
+	require.Nil(t, err)
+	require.Equal(t, `{"version":1,"type":"start-process","payload":{"taskId":2,"processId":3}}`, string(data))
+}
+
+func TestRoundTripInput(t *testing.T) {
+	require.Implements(t, (*input.Status)(nil), roundTripInput(t, input.NewStatus()))
+	require.Implements(t, (*input.Reload)(nil), roundTripInput(t, input.NewReload()))
+	require.Implements(t, (*input.PlanReload)(nil), roundTripInput(t, input.NewPlanReload()))
+	require.Implements(t, (*input.Shutdown)(nil), roundTripInput(t, input.NewShutdown()))
+
+	start := roundTripInput(t, input.NewStartProcess(1, 2)).(input.StartProcess)
+	require.Equal(t, uint(1), start.TaskId())
+	require.Equal(t, uint(2), start.ProcessId())
+
+	stop := roundTripInput(t, input.NewStopProcess(3, 4)).(input.StopProcess)
+	require.Equal(t, uint(3), stop.TaskId())
+	require.Equal(t, uint(4), stop.ProcessId())
+
+	restart := roundTripInput(t, input.NewRestartProcess(5, 6)).(input.RestartProcess)
+	require.Equal(t, uint(5), restart.TaskId())
+	require.Equal(t, uint(6), restart.ProcessId())
+	require.Empty(t, restart.TaskName())
+
+	byName := roundTripInput(t, input.NewStopProcessByName("web", 1)).(input.StopProcess)
+	require.Equal(t, "web", byName.TaskName())
+	require.Equal(t, uint(1), byName.ProcessId())
+}
+
+func TestRoundTripOutputStatus(t *testing.T) {
+	status := roundTripOutput(t, output.NewStatus([]taskOutput.Status{
+		taskOutput.NewStatus(0, "web", []processOutput.Status{
+			processOutput.NewStatus(0, "RUNNING"),
+			processOutput.NewStatus(1, "FAILURE 1 STOPPED"),
+		}),
+		taskOutput.NewStatus(1, "worker", []processOutput.Status{}),
+	})).(output.Status)
+
+	require.Len(t, status.Tasks(), 2)
+	require.Equal(t, "web", status.Tasks()[0].Name())
+	require.Equal(t, uint(1), status.Tasks()[1].TaskId())
+	require.Len(t, status.Tasks()[0].Processes(), 2)
+	require.Equal(t, uint(1), status.Tasks()[0].Processes()[1].ProcessId())
+	require.Equal(t, "FAILURE 1 STOPPED", status.Tasks()[0].Processes()[1].Value())
+	require.Len(t, status.Tasks()[1].Processes(), 0)
+}
+
+func TestRoundTripOutputPlanReload(t *testing.T) {
+	tasks := []config.TaskChange{
+		{Name: "web", Action: config.TASK_SCALED, Properties: []string{"instances"}, Instances: 1, NextInstances: 3},
+		{Name: "worker", Action: config.TASK_ADDED, NextInstances: 2},
+	}
+	plan := roundTripOutput(t, output.NewPlanReloadSuccess(tasks))
+	require.Implements(t, (*output.PlanReloadSuccess)(nil), plan)
+	require.Equal(t, tasks, plan.(output.PlanReloadSuccess).Tasks())
+
+	failure := roundTripOutput(t, output.NewPlanReloadFailure("No task to run"))
+	require.Implements(t, (*output.PlanReloadFailure)(nil), failure)
+	require.Equal(t, "No task to run", failure.(helpers.Failure).Reason())
+}
+
+func TestRoundTripOutputFailure(t *testing.T) {
+	start := roundTripOutput(t, output.NewStartProcessFailure(1, "", 2, "Process already started"))
+	require.Implements(t, (*output.StartProcessFailure)(nil), start)
+	require.Equal(t, "Process already started", start.(helpers.Failure).Reason())
+	require.Equal(t, uint(2), start.(output.StartProcess).ProcessId())
+
+	stop := roundTripOutput(t, output.NewStopProcessSuccess(1, "web", 2))
+	require.Implements(t, (*output.StopProcessSuccess)(nil), stop)
+	require.Equal(t, "web", stop.(output.StopProcess).TaskName())
+
+	restart := roundTripOutput(t, output.NewRestartProcessFailure(3, "", 0, "invalid task id: 3"))
+	require.Implements(t, (*output.RestartProcessFailure)(nil), restart)
+	require.Equal(t, uint(3), restart.(output.RestartProcess).TaskId())
+
+	reload := roundTripOutput(t, output.NewReloadFailure("No task to run"))
+	require.Implements(t, (*output.ReloadFailure)(nil), reload)
+	require.Equal(t, "No task to run", reload.(helpers.Failure).Reason())
+
+	require.Implements(t, (*output.ReloadSuccess)(nil), roundTripOutput(t, output.NewReloadSuccess()))
+	require.Implements(t, (*output.Shutdown)(nil), roundTripOutput(t, output.NewShutdown()))
+	require.Implements(t, (*output.BadRequest)(nil), roundTripOutput(t, output.NewBadRequest()))
+	require.Implements(t, (*output.Forbidden)(nil), roundTripOutput(t, output.NewForbidden()))
+}
+
+func TestDecodeUnsupportedVersion(t *testing.T) {
+	_, err := DecodeInput([]byte(`{"version":2,"type":"status"}`))
+
+	require.NotNil(t, err)
+	require.Equal(t, "Invalid message: unsupported version 2 (expected at most 1)", err.Error())
+}
+
+func TestDecodeMissingPayload(t *testing.T) {
+	_, err := DecodeInput([]byte(`{"version":1,"type":"stop-process"}`))
+
+	require.NotNil(t, err)
+	require.Equal(t, "Invalid message: missing payload for message type stop-process", err.Error())
+}
+
+func TestDecodeUnknownType(t *testing.T) {
+	_, err := DecodeOutput([]byte(`{"version":1,"type":"explode"}`))
+
+	require.NotNil(t, err)
+	require.Equal(t, "Invalid message: unknown output message type explode", err.Error())
+}
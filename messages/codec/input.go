@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"fmt"
+
+	"taskmaster/messages/master/input"
+)
+
+// The task is designated by its name if it has one, or by its id
+type processRequest struct {
+	TaskId    uint   `json:"taskId"`
+	TaskName  string `json:"taskName,omitempty"`
+	ProcessId uint   `json:"processId"`
+}
+
+func EncodeInput(msg input.Message) ([]byte, error) {
+	switch msg.(type) {
+	case input.Status:
+		return encode(TYPE_STATUS, nil)
+	case input.StartProcess:
+		msg := msg.(input.StartProcess)
+		return encode(TYPE_START_PROCESS, processRequest{msg.TaskId(), msg.TaskName(), msg.ProcessId()})
+	case input.StopProcess:
+		msg := msg.(input.StopProcess)
+		return encode(TYPE_STOP_PROCESS, processRequest{msg.TaskId(), msg.TaskName(), msg.ProcessId()})
+	case input.RestartProcess:
+		msg := msg.(input.RestartProcess)
+		return encode(TYPE_RESTART_PROCESS, processRequest{msg.TaskId(), msg.TaskName(), msg.ProcessId()})
+	case input.Reload:
+		return encode(TYPE_RELOAD, nil)
+	case input.PlanReload:
+		return encode(TYPE_PLAN_RELOAD, nil)
+	case input.Shutdown:
+		return encode(TYPE_SHUTDOWN, nil)
+	}
+	return nil, newCodecError(fmt.Sprintf("unknown input message %T", msg))
+}
+
+func DecodeInput(data []byte) (input.Message, error) {
+	envelope, err := decodeEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch envelope.Type {
+	case TYPE_STATUS:
+		return input.NewStatus(), nil
+	case TYPE_START_PROCESS, TYPE_STOP_PROCESS, TYPE_RESTART_PROCESS:
+		var payload processRequest
+		if err := decodePayload(envelope, &payload); err != nil {
+			return nil, err
+		}
+		switch {
+		case envelope.Type == TYPE_START_PROCESS && len(payload.TaskName) != 0:
+			return input.NewStartProcessByName(payload.TaskName, payload.ProcessId), nil
+		case envelope.Type == TYPE_START_PROCESS:
+			return input.NewStartProcess(payload.TaskId, payload.ProcessId), nil
+		case envelope.Type == TYPE_STOP_PROCESS && len(payload.TaskName) != 0:
+			return input.NewStopProcessByName(payload.TaskName, payload.ProcessId), nil
+		case envelope.Type == TYPE_STOP_PROCESS:
+			return input.NewStopProcess(payload.TaskId, payload.ProcessId), nil
+		case len(payload.TaskName) != 0:
+			return input.NewRestartProcessByName(payload.TaskName, payload.ProcessId), nil
+		default:
+			return input.NewRestartProcess(payload.TaskId, payload.ProcessId), nil
+		}
+	case TYPE_RELOAD:
+		return input.NewReload(), nil
+	case TYPE_PLAN_RELOAD:
+		return input.NewPlanReload(), nil
+	case TYPE_SHUTDOWN:
+		return input.NewShutdown(), nil
+	}
+	return nil, newCodecError(fmt.Sprintf("unknown input message type %s", envelope.Type))
+}
@@ -0,0 +1,187 @@
+package codec
+
+import (
+	"fmt"
+
+	"taskmaster/config"
+	"taskmaster/messages/helpers"
+	"taskmaster/messages/master/output"
+	processOutput "taskmaster/messages/process/output"
+	taskOutput "taskmaster/messages/task/output"
+)
+
+// Payload of the responses that either succeed or fail
+type result struct {
+	Success bool   `json:"success"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type processResult struct {
+	TaskId    uint   `json:"taskId"`
+	TaskName  string `json:"taskName,omitempty"`
+	ProcessId uint   `json:"processId"`
+	result
+}
+
+type processStatus struct {
+	ProcessId uint   `json:"processId"`
+	Value     string `json:"value"`
+}
+
+type taskStatus struct {
+	TaskId    uint            `json:"taskId"`
+	Name      string          `json:"name"`
+	Processes []processStatus `json:"processes"`
+}
+
+type status struct {
+	Tasks []taskStatus `json:"tasks"`
+}
+
+type taskChange struct {
+	Name          string   `json:"name"`
+	Action        string   `json:"action"`
+	Properties    []string `json:"properties,omitempty"`
+	Instances     uint     `json:"instances"`
+	NextInstances uint     `json:"nextInstances"`
+}
+
+type reloadPlan struct {
+	result
+	Tasks []taskChange `json:"tasks,omitempty"`
+}
+
+func newResult(msg output.Message) result {
+	if failure, ok := msg.(helpers.Failure); ok {
+		return result{Success: false, Reason: failure.Reason()}
+	}
+	return result{Success: true}
+}
+
+func newStatus(msg output.Status) status {
+	payload := status{Tasks: []taskStatus{}}
+	for _, task := range msg.Tasks() {
+		processes := []processStatus{}
+		for _, proc := range task.Processes() {
+			processes = append(processes, processStatus{proc.ProcessId(), proc.Value()})
+		}
+		payload.Tasks = append(payload.Tasks, taskStatus{task.TaskId(), task.Name(), processes})
+	}
+	return payload
+}
+
+func newReloadPlan(msg output.PlanReload) reloadPlan {
+	payload := reloadPlan{result: newResult(msg)}
+	if success, ok := msg.(output.PlanReloadSuccess); ok {
+		payload.Tasks = []taskChange{}
+		for _, change := range success.Tasks() {
+			payload.Tasks = append(payload.Tasks, taskChange(change))
+		}
+	}
+	return payload
+}
+
+func EncodeOutput(msg output.Message) ([]byte, error) {
+	switch msg.(type) {
+	case output.Status:
+		return encode(TYPE_STATUS, newStatus(msg.(output.Status)))
+	case output.StartProcess:
+		msg := msg.(output.StartProcess)
+		return encode(TYPE_START_PROCESS, processResult{msg.TaskId(), msg.TaskName(), msg.ProcessId(), newResult(msg)})
+	case output.StopProcess:
+		msg := msg.(output.StopProcess)
+		return encode(TYPE_STOP_PROCESS, processResult{msg.TaskId(), msg.TaskName(), msg.ProcessId(), newResult(msg)})
+	case output.RestartProcess:
+		msg := msg.(output.RestartProcess)
+		return encode(TYPE_RESTART_PROCESS, processResult{msg.TaskId(), msg.TaskName(), msg.ProcessId(), newResult(msg)})
+	case output.Reload:
+		return encode(TYPE_RELOAD, newResult(msg))
+	case output.PlanReload:
+		return encode(TYPE_PLAN_RELOAD, newReloadPlan(msg.(output.PlanReload)))
+	case output.Shutdown:
+		return encode(TYPE_SHUTDOWN, nil)
+	case output.BadRequest:
+		return encode(TYPE_BAD_REQUEST, nil)
+	case output.Forbidden:
+		return encode(TYPE_FORBIDDEN, nil)
+	}
+	return nil, newCodecError(fmt.Sprintf("unknown output message %T", msg))
+}
+
+func DecodeOutput(data []byte) (output.Message, error) {
+	envelope, err := decodeEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch envelope.Type {
+	case TYPE_STATUS:
+		var payload status
+		if err := decodePayload(envelope, &payload); err != nil {
+			return nil, err
+		}
+		tasks := []taskOutput.Status{}
+		for _, task := range payload.Tasks {
+			processes := []processOutput.Status{}
+			for _, proc := range task.Processes {
+				processes = append(processes, processOutput.NewStatus(proc.ProcessId, proc.Value))
+			}
+			tasks = append(tasks, taskOutput.NewStatus(task.TaskId, task.Name, processes))
+		}
+		return output.NewStatus(tasks), nil
+
+	case TYPE_START_PROCESS, TYPE_STOP_PROCESS, TYPE_RESTART_PROCESS:
+		var payload processResult
+		if err := decodePayload(envelope, &payload); err != nil {
+			return nil, err
+		}
+		switch {
+		case envelope.Type == TYPE_START_PROCESS && payload.Success:
+			return output.NewStartProcessSuccess(payload.TaskId, payload.TaskName, payload.ProcessId), nil
+		case envelope.Type == TYPE_START_PROCESS:
+			return output.NewStartProcessFailure(payload.TaskId, payload.TaskName, payload.ProcessId, payload.Reason), nil
+		case envelope.Type == TYPE_STOP_PROCESS && payload.Success:
+			return output.NewStopProcessSuccess(payload.TaskId, payload.TaskName, payload.ProcessId), nil
+		case envelope.Type == TYPE_STOP_PROCESS:
+			return output.NewStopProcessFailure(payload.TaskId, payload.TaskName, payload.ProcessId, payload.Reason), nil
+		case payload.Success:
+			return output.NewRestartProcessSuccess(payload.TaskId, payload.TaskName, payload.ProcessId), nil
+		default:
+			return output.NewRestartProcessFailure(payload.TaskId, payload.TaskName, payload.ProcessId, payload.Reason), nil
+		}
+
+	case TYPE_RELOAD:
+		var payload result
+		if err := decodePayload(envelope, &payload); err != nil {
+			return nil, err
+		}
+		if payload.Success {
+			return output.NewReloadSuccess(), nil
+		}
+		return output.NewReloadFailure(payload.Reason), nil
+
+	case TYPE_PLAN_RELOAD:
+		var payload reloadPlan
+		if err := decodePayload(envelope, &payload); err != nil {
+			return nil, err
+		}
+		if !payload.Success {
+			return output.NewPlanReloadFailure(payload.Reason), nil
+		}
+		tasks := []config.TaskChange{}
+		for _, change := range payload.Tasks {
+			tasks = append(tasks, config.TaskChange(change))
+		}
+		return output.NewPlanReloadSuccess(tasks), nil
+
+	case TYPE_SHUTDOWN:
+		return output.NewShutdown(), nil
+
+	case TYPE_BAD_REQUEST:
+		return output.NewBadRequest(), nil
+
+	case TYPE_FORBIDDEN:
+		return output.NewForbidden(), nil
+	}
+	return nil, newCodecError(fmt.Sprintf("unknown output message type %s", envelope.Type))
+}
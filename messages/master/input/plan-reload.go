@@ -0,0 +1,12 @@
+package input
+
+type PlanReload interface {
+	Message
+	isPlanReload() bool
+}
+
+type planReload struct{ message }
+
+func (*planReload) isPlanReload() bool { return true }
+
+func NewPlanReload() PlanReload { return &planReload{} }
@@ -1,22 +1,30 @@
 package input
 
+// The task is designated by `TaskName` if it is not empty, or by `TaskId`
 type RestartProcess interface {
 	Message
 	isRestartProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
 type restartProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
 func (*restartProcess) isRestartProcess() bool { return true }
 func (this *restartProcess) TaskId() uint      { return this.taskId }
+func (this *restartProcess) TaskName() string  { return this.taskName }
 func (this *restartProcess) ProcessId() uint   { return this.processId }
 
 func NewRestartProcess(taskId, processId uint) RestartProcess {
 	return &restartProcess{taskId: taskId, processId: processId}
 }
+
+func NewRestartProcessByName(taskName string, processId uint) RestartProcess {
+	return &restartProcess{taskName: taskName, processId: processId}
+}
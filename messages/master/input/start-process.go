@@ -1,22 +1,30 @@
 package input
 
+// The task is designated by `TaskName` if it is not empty, or by `TaskId`
 type StartProcess interface {
 	Message
 	isStartProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
 type startProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
-func (*startProcess) isStartProcess() bool { return true }
-func (this *startProcess) TaskId() uint    { return this.taskId }
-func (this *startProcess) ProcessId() uint { return this.processId }
+func (*startProcess) isStartProcess() bool  { return true }
+func (this *startProcess) TaskId() uint     { return this.taskId }
+func (this *startProcess) TaskName() string { return this.taskName }
+func (this *startProcess) ProcessId() uint  { return this.processId }
 
 func NewStartProcess(taskId, processId uint) StartProcess {
 	return &startProcess{taskId: taskId, processId: processId}
 }
+
+func NewStartProcessByName(taskName string, processId uint) StartProcess {
+	return &startProcess{taskName: taskName, processId: processId}
+}
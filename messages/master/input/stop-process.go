@@ -1,22 +1,30 @@
 package input
 
+// The task is designated by `TaskName` if it is not empty, or by `TaskId`
 type StopProcess interface {
 	Message
 	isStopProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
 type stopProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
-func (*stopProcess) isStopProcess() bool  { return true }
-func (this *stopProcess) TaskId() uint    { return this.taskId }
-func (this *stopProcess) ProcessId() uint { return this.processId }
+func (*stopProcess) isStopProcess() bool   { return true }
+func (this *stopProcess) TaskId() uint     { return this.taskId }
+func (this *stopProcess) TaskName() string { return this.taskName }
+func (this *stopProcess) ProcessId() uint  { return this.processId }
 
 func NewStopProcess(taskId, processId uint) StopProcess {
 	return &stopProcess{taskId: taskId, processId: processId}
 }
+
+func NewStopProcessByName(taskName string, processId uint) StopProcess {
+	return &stopProcess{taskName: taskName, processId: processId}
+}
@@ -0,0 +1,12 @@
+package output
+
+type Forbidden interface {
+	Message
+	isForbidden() bool
+}
+
+type forbidden struct{ message }
+
+func (*forbidden) isForbidden() bool { return true }
+
+func NewForbidden() Forbidden { return &forbidden{} }
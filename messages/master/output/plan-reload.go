@@ -0,0 +1,48 @@
+package output
+
+import (
+	"taskmaster/config"
+	"taskmaster/messages/helpers"
+)
+
+type PlanReload interface {
+	Message
+	isPlanReload() bool
+}
+
+type PlanReloadSuccess interface {
+	PlanReload
+	helpers.Success
+	Tasks() []config.TaskChange
+}
+
+type PlanReloadFailure interface {
+	PlanReload
+	helpers.Failure
+}
+
+type planReload struct{ message }
+
+type planReloadSuccess struct {
+	planReload
+	helpers.BaseSuccess
+	tasks []config.TaskChange
+}
+
+type planReloadFailure struct {
+	planReload
+	helpers.BaseFailure
+}
+
+func (*planReload) isPlanReload() bool                     { return true }
+func (this *planReloadSuccess) Tasks() []config.TaskChange { return this.tasks }
+
+func NewPlanReloadSuccess(tasks []config.TaskChange) PlanReloadSuccess {
+	return &planReloadSuccess{tasks: tasks}
+}
+
+func NewPlanReloadFailure(reason string) PlanReloadFailure {
+	instance := planReloadFailure{}
+	instance.SetReason(reason)
+	return &instance
+}
@@ -7,7 +7,9 @@ import (
 
 type RestartProcess interface {
 	Message
+	isRestartProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
@@ -24,6 +26,7 @@ type RestartProcessFailure interface {
 type restartProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
@@ -39,33 +42,36 @@ type restartProcessFailure struct {
 
 func (*restartProcess) isRestartProcess() bool { return true }
 func (this *restartProcess) TaskId() uint      { return this.taskId }
+func (this *restartProcess) TaskName() string  { return this.taskName }
 func (this *restartProcess) ProcessId() uint   { return this.processId }
 
-func NewRestartProcess(taskId uint, response taskOutput.RestartProcess) RestartProcess {
+func NewRestartProcess(taskName string, response taskOutput.RestartProcess) RestartProcess {
 	switch response.(type) {
 	case taskOutput.RestartProcessSuccess:
-		response := response.(RestartProcessSuccess)
-		return NewRestartProcessSuccess(taskId, response.ProcessId())
+		response := response.(taskOutput.RestartProcessSuccess)
+		return NewRestartProcessSuccess(response.TaskId(), taskName, response.ProcessId())
 	case taskOutput.RestartProcessFailure:
 		response := response.(taskOutput.RestartProcessFailure)
-		return NewRestartProcessFailure(taskId, response.ProcessId(), response.Reason())
+		return NewRestartProcessFailure(response.TaskId(), taskName, response.ProcessId(), response.Reason())
 	}
 	return nil
 }
 
-func NewRestartProcessSuccess(taskId, processId uint) RestartProcessSuccess {
+func NewRestartProcessSuccess(taskId uint, taskName string, processId uint) RestartProcessSuccess {
 	return &restartProcessSuccess{
 		restartProcess: restartProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
 }
 
-func NewRestartProcessFailure(taskId, processId uint, reason string) RestartProcessFailure {
+func NewRestartProcessFailure(taskId uint, taskName string, processId uint, reason string) RestartProcessFailure {
 	instance := restartProcessFailure{
 		restartProcess: restartProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
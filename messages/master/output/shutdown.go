@@ -0,0 +1,12 @@
+package output
+
+type Shutdown interface {
+	Message
+	isShutdown() bool
+}
+
+type shutdown struct{ message }
+
+func (*shutdown) isShutdown() bool { return true }
+
+func NewShutdown() Shutdown { return &shutdown{} }
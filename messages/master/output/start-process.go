@@ -7,7 +7,9 @@ import (
 
 type StartProcess interface {
 	Message
+	isStartProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
@@ -24,6 +26,7 @@ type StartProcessFailure interface {
 type startProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
@@ -37,35 +40,38 @@ type startProcessFailure struct {
 	helpers.BaseFailure
 }
 
-func (*startProcess) isStartProcess() bool { return true }
-func (this *startProcess) TaskId() uint    { return this.taskId }
-func (this *startProcess) ProcessId() uint { return this.processId }
+func (*startProcess) isStartProcess() bool  { return true }
+func (this *startProcess) TaskId() uint     { return this.taskId }
+func (this *startProcess) TaskName() string { return this.taskName }
+func (this *startProcess) ProcessId() uint  { return this.processId }
 
-func NewStartProcess(taskId uint, response taskOutput.StartProcess) StartProcess {
+func NewStartProcess(taskName string, response taskOutput.StartProcess) StartProcess {
 	switch response.(type) {
 	case taskOutput.StartProcessSuccess:
 		response := response.(taskOutput.StartProcessSuccess)
-		return NewStartProcessSuccess(taskId, response.ProcessId())
+		return NewStartProcessSuccess(response.TaskId(), taskName, response.ProcessId())
 	case taskOutput.StartProcessFailure:
 		response := response.(taskOutput.StartProcessFailure)
-		return NewStartProcessFailure(taskId, response.ProcessId(), response.Reason())
+		return NewStartProcessFailure(response.TaskId(), taskName, response.ProcessId(), response.Reason())
 	}
 	return nil
 }
 
-func NewStartProcessSuccess(taskId, processId uint) StartProcessSuccess {
+func NewStartProcessSuccess(taskId uint, taskName string, processId uint) StartProcessSuccess {
 	return &startProcessSuccess{
 		startProcess: startProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
 }
 
-func NewStartProcessFailure(taskId, processId uint, reason string) StartProcessFailure {
+func NewStartProcessFailure(taskId uint, taskName string, processId uint, reason string) StartProcessFailure {
 	instance := startProcessFailure{
 		startProcess: startProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
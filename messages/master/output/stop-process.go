@@ -7,7 +7,9 @@ import (
 
 type StopProcess interface {
 	Message
+	isStopProcess() bool
 	TaskId() uint
+	TaskName() string
 	ProcessId() uint
 }
 
@@ -24,6 +26,7 @@ type StopProcessFailure interface {
 type stopProcess struct {
 	message
 	taskId    uint
+	taskName  string
 	processId uint
 }
 
@@ -37,35 +40,38 @@ type stopProcessFailure struct {
 	helpers.BaseFailure
 }
 
-func (*stopProcess) isStopProcess() bool  { return true }
-func (this *stopProcess) TaskId() uint    { return this.taskId }
-func (this *stopProcess) ProcessId() uint { return this.processId }
+func (*stopProcess) isStopProcess() bool   { return true }
+func (this *stopProcess) TaskId() uint     { return this.taskId }
+func (this *stopProcess) TaskName() string { return this.taskName }
+func (this *stopProcess) ProcessId() uint  { return this.processId }
 
-func NewStopProcess(taskId uint, response taskOutput.StopProcess) StopProcess {
+func NewStopProcess(taskName string, response taskOutput.StopProcess) StopProcess {
 	switch response.(type) {
 	case taskOutput.StopProcessSuccess:
 		response := response.(taskOutput.StopProcessSuccess)
-		return NewStopProcessSuccess(taskId, response.ProcessId())
+		return NewStopProcessSuccess(response.TaskId(), taskName, response.ProcessId())
 	case taskOutput.StopProcessFailure:
 		response := response.(taskOutput.StopProcessFailure)
-		return NewStopProcessFailure(taskId, response.ProcessId(), response.Reason())
+		return NewStopProcessFailure(response.TaskId(), taskName, response.ProcessId(), response.Reason())
 	}
 	return nil
 }
 
-func NewStopProcessSuccess(taskId, processId uint) StopProcessSuccess {
+func NewStopProcessSuccess(taskId uint, taskName string, processId uint) StopProcessSuccess {
 	return &stopProcessSuccess{
 		stopProcess: stopProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
 }
 
-func NewStopProcessFailure(taskId, processId uint, reason string) StopProcessFailure {
+func NewStopProcessFailure(taskId uint, taskName string, processId uint, reason string) StopProcessFailure {
 	instance := stopProcessFailure{
 		stopProcess: stopProcess{
 			taskId:    taskId,
+			taskName:  taskName,
 			processId: processId,
 		},
 	}
@@ -0,0 +1,31 @@
+package input
+
+import (
+	"taskmaster/config"
+	"taskmaster/messages/helpers"
+)
+
+// Apply the live properties of a new configuration of the task to the
+// process without restarting it, `TaskId` being the new position of the task
+type Update interface {
+	Message
+	helpers.Local
+	isUpdate() bool
+	Config() config.Task
+	TaskId() uint
+}
+
+type update struct {
+	message
+	helpers.BaseLocal
+	config config.Task
+	taskId uint
+}
+
+func (*update) isUpdate() bool           { return true }
+func (this *update) Config() config.Task { return this.config }
+func (this *update) TaskId() uint        { return this.taskId }
+
+func NewUpdate(config config.Task, taskId uint) Update {
+	return &update{config: config, taskId: taskId}
+}
@@ -0,0 +1,25 @@
+package input
+
+import "taskmaster/messages/helpers"
+
+// Start the instances added by the last Update and stop the ones it left
+// over, signaling `Done` once they are.
+type CommitUpdate interface {
+	Message
+	helpers.Local
+	isCommitUpdate() bool
+	Done() chan<- struct{}
+}
+
+type commitUpdate struct {
+	message
+	helpers.BaseLocal
+	done chan<- struct{}
+}
+
+func (*commitUpdate) isCommitUpdate() bool       { return true }
+func (this *commitUpdate) Done() chan<- struct{} { return this.done }
+
+func NewCommitUpdate(done chan<- struct{}) CommitUpdate {
+	return &commitUpdate{done: done}
+}
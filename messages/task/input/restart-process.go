@@ -2,22 +2,27 @@ package input
 
 import "taskmaster/messages/helpers"
 
+// `TaskId` is the id of the task in the request to the master, for the
+// response to be matched with the request even if a reload changed it
 type RestartProcess interface {
 	Message
 	helpers.Local
 	isRestartProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
 type restartProcess struct {
 	message
 	helpers.BaseLocal
+	taskId    uint
 	processId uint
 }
 
 func (*restartProcess) isRestartProcess() bool { return true }
+func (this *restartProcess) TaskId() uint      { return this.taskId }
 func (this *restartProcess) ProcessId() uint   { return this.processId }
 
-func NewRestartProcess(processId uint) RestartProcess {
-	return &restartProcess{processId: processId}
+func NewRestartProcess(taskId, processId uint) RestartProcess {
+	return &restartProcess{taskId: taskId, processId: processId}
 }
@@ -2,22 +2,27 @@ package input
 
 import "taskmaster/messages/helpers"
 
+// `TaskId` is the id of the task in the request to the master, for the
+// response to be matched with the request even if a reload changed it
 type StartProcess interface {
 	Message
 	helpers.Local
 	isStartProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
 type startProcess struct {
 	message
 	helpers.BaseLocal
+	taskId    uint
 	processId uint
 }
 
 func (*startProcess) isStartProcess() bool { return true }
+func (this *startProcess) TaskId() uint    { return this.taskId }
 func (this *startProcess) ProcessId() uint { return this.processId }
 
-func NewStartProcess(processId uint) StartProcess {
-	return &startProcess{processId: processId}
+func NewStartProcess(taskId, processId uint) StartProcess {
+	return &startProcess{taskId: taskId, processId: processId}
 }
@@ -1,19 +1,24 @@
 package input
 
+// `TaskId` is the id of the task in the request to the master, for the
+// response to be matched with the request even if a reload changed it
 type StopProcess interface {
 	Message
 	isStopProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
 type stopProcess struct {
 	message
+	taskId    uint
 	processId uint
 }
 
 func (*stopProcess) isStopProcess() bool  { return true }
+func (this *stopProcess) TaskId() uint    { return this.taskId }
 func (this *stopProcess) ProcessId() uint { return this.processId }
 
-func NewStopProcess(processId uint) StopProcess {
-	return &stopProcess{processId: processId}
+func NewStopProcess(taskId, processId uint) StopProcess {
+	return &stopProcess{taskId: taskId, processId: processId}
 }
@@ -0,0 +1,37 @@
+package input
+
+import (
+	"taskmaster/config"
+	"taskmaster/messages/helpers"
+)
+
+// Apply a new configuration to the task without restarting its processes.
+// `TaskId` is the position of the task in the new configuration, and the
+// outcome is sent on `Result`. If the number of instances changed, they are
+// only started or stopped by the following CommitUpdate, until then another
+// Update can undo this one.
+type Update interface {
+	Message
+	helpers.Local
+	isUpdate() bool
+	Config() *config.Config
+	TaskId() uint
+	Result() chan<- error
+}
+
+type update struct {
+	message
+	helpers.BaseLocal
+	config *config.Config
+	taskId uint
+	result chan<- error
+}
+
+func (*update) isUpdate() bool              { return true }
+func (this *update) Config() *config.Config { return this.config }
+func (this *update) TaskId() uint           { return this.taskId }
+func (this *update) Result() chan<- error   { return this.result }
+
+func NewUpdate(config *config.Config, taskId uint, result chan<- error) Update {
+	return &update{config: config, taskId: taskId, result: result}
+}
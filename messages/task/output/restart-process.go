@@ -9,6 +9,7 @@ type RestartProcess interface {
 	Message
 	helpers.Local
 	isRestartProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
@@ -27,6 +28,7 @@ type RestartProcessFailure interface {
 type restartProcess struct {
 	message
 	helpers.BaseLocal
+	taskId    uint
 	processId uint
 }
 
@@ -41,28 +43,29 @@ type restartProcessFailure struct {
 }
 
 func (*restartProcess) isRestartProcess() bool { return true }
+func (this *restartProcess) TaskId() uint      { return this.taskId }
 func (this *restartProcess) ProcessId() uint   { return this.processId }
 
-func NewRestartProcess(processId uint, response processOutput.Restart) RestartProcess {
+func NewRestartProcess(taskId, processId uint, response processOutput.Restart) RestartProcess {
 	switch response.(type) {
 	case processOutput.RestartSuccess:
-		return NewRestartProcessSuccess(processId)
+		return NewRestartProcessSuccess(taskId, processId)
 	case processOutput.RestartFailure:
-		response := response.(processOutput.StartFailure)
-		return NewRestartProcessFailure(processId, response.Reason())
+		response := response.(processOutput.RestartFailure)
+		return NewRestartProcessFailure(taskId, processId, response.Reason())
 	}
 	return nil
 }
 
-func NewRestartProcessSuccess(processId uint) RestartProcessSuccess {
+func NewRestartProcessSuccess(taskId, processId uint) RestartProcessSuccess {
 	return &restartProcessSuccess{
-		restartProcess: restartProcess{processId: processId},
+		restartProcess: restartProcess{taskId: taskId, processId: processId},
 	}
 }
 
-func NewRestartProcessFailure(processId uint, reason string) RestartProcessFailure {
+func NewRestartProcessFailure(taskId, processId uint, reason string) RestartProcessFailure {
 	instance := restartProcessFailure{
-		restartProcess: restartProcess{processId: processId},
+		restartProcess: restartProcess{taskId: taskId, processId: processId},
 	}
 	instance.SetReason(reason)
 	return &instance
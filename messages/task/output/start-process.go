@@ -9,6 +9,7 @@ type StartProcess interface {
 	Message
 	helpers.Local
 	isStartProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
@@ -27,6 +28,7 @@ type StartProcessFailure interface {
 type startProcess struct {
 	message
 	helpers.BaseLocal
+	taskId    uint
 	processId uint
 }
 
@@ -41,28 +43,29 @@ type startProcessFailure struct {
 }
 
 func (*startProcess) isStartProcess() bool { return true }
+func (this *startProcess) TaskId() uint    { return this.taskId }
 func (this *startProcess) ProcessId() uint { return this.processId }
 
-func NewStartProcess(processId uint, response processOutput.Start) StartProcess {
+func NewStartProcess(taskId, processId uint, response processOutput.Start) StartProcess {
 	switch response.(type) {
 	case processOutput.StartSuccess:
-		return NewStartProcessSuccess(processId)
+		return NewStartProcessSuccess(taskId, processId)
 	case processOutput.StartFailure:
 		response := response.(processOutput.StartFailure)
-		return NewStartProcessFailure(processId, response.Reason())
+		return NewStartProcessFailure(taskId, processId, response.Reason())
 	}
 	return nil
 }
 
-func NewStartProcessSuccess(processId uint) StartProcessSuccess {
+func NewStartProcessSuccess(taskId, processId uint) StartProcessSuccess {
 	return &startProcessSuccess{
-		startProcess: startProcess{processId: processId},
+		startProcess: startProcess{taskId: taskId, processId: processId},
 	}
 }
 
-func NewStartProcessFailure(processId uint, reason string) StartProcessFailure {
+func NewStartProcessFailure(taskId, processId uint, reason string) StartProcessFailure {
 	instance := startProcessFailure{
-		startProcess: startProcess{processId: processId},
+		startProcess: startProcess{taskId: taskId, processId: processId},
 	}
 	instance.SetReason(reason)
 	return &instance
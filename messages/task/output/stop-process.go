@@ -9,6 +9,7 @@ type StopProcess interface {
 	Message
 	helpers.Local
 	isStopProcess() bool
+	TaskId() uint
 	ProcessId() uint
 }
 
@@ -25,6 +26,7 @@ type StopProcessFailure interface {
 type stopProcess struct {
 	message
 	helpers.BaseLocal
+	taskId    uint
 	processId uint
 }
 
@@ -39,28 +41,29 @@ type stopProcessFailure struct {
 }
 
 func (*stopProcess) isStopProcess() bool  { return true }
+func (this *stopProcess) TaskId() uint    { return this.taskId }
 func (this *stopProcess) ProcessId() uint { return this.processId }
 
-func NewStopProcess(processId uint, response processOutput.Stop) StopProcess {
+func NewStopProcess(taskId, processId uint, response processOutput.Stop) StopProcess {
 	switch response.(type) {
 	case processOutput.StopSuccess:
-		return NewStopProcessSuccess(processId)
+		return NewStopProcessSuccess(taskId, processId)
 	case processOutput.StopFailure:
 		response := response.(processOutput.StopFailure)
-		return NewStopProcessFailure(processId, response.Reason())
+		return NewStopProcessFailure(taskId, processId, response.Reason())
 	}
 	return nil
 }
 
-func NewStopProcessSuccess(processId uint) StopProcessSuccess {
+func NewStopProcessSuccess(taskId, processId uint) StopProcessSuccess {
 	return &stopProcessSuccess{
-		stopProcess: stopProcess{processId: processId},
+		stopProcess: stopProcess{taskId: taskId, processId: processId},
 	}
 }
 
-func NewStopProcessFailure(processId uint, reason string) StopProcessFailure {
+func NewStopProcessFailure(taskId, processId uint, reason string) StopProcessFailure {
 	instance := stopProcessFailure{
-		stopProcess: stopProcess{processId: processId},
+		stopProcess: stopProcess{taskId: taskId, processId: processId},
 	}
 	instance.SetReason(reason)
 	return &instance
@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/events"
+)
+
+// State of a single process instance, as rebuilt from its events
+type processMetrics struct {
+	running        bool
+	startRetries   uint
+	restarts       uint
+	exitStatus     *int
+	startedAt      *time.Time
+	startingSince  *time.Time
+	startingTotal  time.Duration
+	lastStartingAt time.Duration
+}
+
+type processKey struct {
+	task     string
+	instance uint
+}
+
+// Build Prometheus metrics from the events published on a broker
+type Collector struct {
+	manager config.Manager
+
+	lock      *sync.Mutex
+	processes map[processKey]*processMetrics
+	reloads   map[string]uint
+}
+
+func NewCollector(manager config.Manager, broker *events.Broker) *Collector {
+	instance := &Collector{
+		manager:   manager,
+		lock:      new(sync.Mutex),
+		processes: map[processKey]*processMetrics{},
+		reloads:   map[string]uint{"success": 0, "failure": 0},
+	}
+	stream, _ := broker.SubscribeAll()
+	go func() {
+		for event := range stream {
+			instance.collect(event)
+		}
+	}()
+	return instance
+}
+
+func (this *Collector) process(key processKey) *processMetrics {
+	if _, ok := this.processes[key]; !ok {
+		this.processes[key] = &processMetrics{}
+	}
+	return this.processes[key]
+}
+
+func (this *Collector) collect(event events.Event) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	switch event.Type {
+	case events.RELOAD_SUCCEEDED:
+		this.reloads["success"]++
+		return
+	case events.RELOAD_FAILED:
+		this.reloads["failure"]++
+		return
+	}
+
+	process := this.process(processKey{event.Task, event.Instance})
+	process.startRetries = event.Retries
+
+	stopStarting := func() {
+		if process.startingSince != nil {
+			process.lastStartingAt = event.Time.Sub(*process.startingSince)
+			process.startingTotal += process.lastStartingAt
+			process.startingSince = nil
+		}
+	}
+
+	switch event.Type {
+	case "STARTING", "RETRYING", "RESTARTING":
+		if event.Type != "STARTING" {
+			process.restarts++
+		}
+		if event.Type != "RESTARTING" {
+			process.startingSince = &event.Time
+		}
+	case "STARTED":
+		process.running = true
+		process.startedAt = event.StartedAt
+		if process.startingSince == nil {
+			process.startingSince = event.StartedAt
+		}
+		stopStarting()
+	case "STOPPED_SUCCESSFULLY", "STOPPED_UNSUCCESSFULLY":
+		process.running = false
+		process.startedAt = nil
+		process.exitStatus = event.ExitCode
+		stopStarting()
+	case "STARTING_ERROR", "RESTARTING_ERROR":
+		process.running = false
+		process.startedAt = nil
+		process.startingSince = nil
+	}
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+type sample struct {
+	labels string
+	value  float64
+}
+
+func writeMetric(w io.Writer, name, kind, help string, samples []sample) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	for _, sample := range samples {
+		fmt.Fprintf(w, "%s{%s} %g\n", name, sample.labels, sample.value)
+	}
+}
+
+// Write every metric to `w` using the Prometheus text exposition format
+func (this *Collector) Write(w io.Writer) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	boolValue := func(value bool) float64 {
+		if value {
+			return 1
+		}
+		return 0
+	}
+
+	var instances, runningInstances, running, retries, restarts, exitStatus, uptime, starting, startingTotal, lastStarting []sample
+
+	for _, task := range this.manager.Get().Tasks {
+		taskLabels := fmt.Sprintf(`task="%s"`, escapeLabel(*task.Name))
+		runningCount := 0
+		for i := uint(0); i < task.Instances; i++ {
+			process := this.process(processKey{*task.Name, i})
+			labels := fmt.Sprintf(`%s,instance="%d"`, taskLabels, i)
+
+			if process.running {
+				runningCount++
+			}
+			running = append(running, sample{labels, boolValue(process.running)})
+			retries = append(retries, sample{labels, float64(process.startRetries)})
+			restarts = append(restarts, sample{labels, float64(process.restarts)})
+			if process.exitStatus != nil {
+				exitStatus = append(exitStatus, sample{labels, float64(*process.exitStatus)})
+			}
+			if process.running && process.startedAt != nil {
+				uptime = append(uptime, sample{labels, now.Sub(*process.startedAt).Seconds()})
+			} else {
+				uptime = append(uptime, sample{labels, 0})
+			}
+			currentStarting := time.Duration(0)
+			if process.startingSince != nil {
+				currentStarting = now.Sub(*process.startingSince)
+			}
+			starting = append(starting, sample{labels, boolValue(process.startingSince != nil)})
+			startingTotal = append(startingTotal, sample{labels, (process.startingTotal + currentStarting).Seconds()})
+			lastStarting = append(lastStarting, sample{labels, process.lastStartingAt.Seconds()})
+		}
+		instances = append(instances, sample{taskLabels, float64(task.Instances)})
+		runningInstances = append(runningInstances, sample{taskLabels, float64(runningCount)})
+	}
+
+	writeMetric(w, "taskmaster_task_instances", "gauge", "Number of configured instances of the task", instances)
+	writeMetric(w, "taskmaster_task_running_instances", "gauge", "Number of running instances of the task", runningInstances)
+	writeMetric(w, "taskmaster_process_running", "gauge", "Whether the process is running (1) or not (0)", running)
+	writeMetric(w, "taskmaster_process_starting", "gauge", "Whether the process is starting (1) or not (0)", starting)
+	writeMetric(w, "taskmaster_process_start_retries", "gauge", "Number of times the process was restarted after failing, out of its restart attempts", retries)
+	writeMetric(w, "taskmaster_process_restarts_total", "counter", "Number of times the process was restarted, automatically or manually", restarts)
+	writeMetric(w, "taskmaster_process_last_exit_status", "gauge", "Exit status of the last run of the process (-1 if it was killed by a signal)", exitStatus)
+	writeMetric(w, "taskmaster_process_uptime_seconds", "gauge", "Time since the running process was started", uptime)
+	writeMetric(w, "taskmaster_process_starting_seconds_total", "counter", "Time spent by the process in the STARTING state", startingTotal)
+	writeMetric(w, "taskmaster_process_last_starting_seconds", "gauge", "Time spent by the process in the STARTING state during its last start", lastStarting)
+	writeMetric(w, "taskmaster_reloads_total", "counter", "Number of configuration reloads", []sample{
+		{`result="success"`, float64(this.reloads["success"])},
+		{`result="failure"`, float64(this.reloads["failure"])},
+	})
+}
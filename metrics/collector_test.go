@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/events"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCollector(t *testing.T) *Collector {
+	manager, err := config.NewManager("testdata/config.json")
+	require.Nil(t, err)
+	return NewCollector(manager, events.NewBroker(0))
+}
+
+func write(collector *Collector) string {
+	builder := new(strings.Builder)
+	collector.Write(builder)
+	return builder.String()
+}
+
+func TestCollectProcessLifecycle(t *testing.T) {
+	collector := newTestCollector(t)
+	start := time.Now()
+	started := start.Add(2 * time.Second)
+	exitCode := 3
+
+	collector.collect(events.Event{Time: start, Type: "STARTING", Task: "web", Instance: 1})
+	collector.collect(events.Event{Time: started, Type: "STARTED", Task: "web", Instance: 1, StartedAt: &started})
+	metrics := write(collector)
+
+	require.Contains(t, metrics, "taskmaster_task_instances{task=\"web\"} 2\n")
+	require.Contains(t, metrics, "taskmaster_task_running_instances{task=\"web\"} 1\n")
+	require.Contains(t, metrics, "taskmaster_process_running{task=\"web\",instance=\"0\"} 0\n")
+	require.Contains(t, metrics, "taskmaster_process_running{task=\"web\",instance=\"1\"} 1\n")
+	require.Contains(t, metrics, "taskmaster_process_last_starting_seconds{task=\"web\",instance=\"1\"} 2\n")
+	require.NotContains(t, metrics, "taskmaster_process_last_exit_status{")
+
+	collector.collect(events.Event{Time: started, Type: "RETRYING", Task: "web", Instance: 1, Retries: 1})
+	collector.collect(events.Event{Time: started, Type: "STOPPED_UNSUCCESSFULLY", Task: "web", Instance: 1, Retries: 1, ExitCode: &exitCode})
+	metrics = write(collector)
+
+	require.Contains(t, metrics, "taskmaster_process_running{task=\"web\",instance=\"1\"} 0\n")
+	require.Contains(t, metrics, "taskmaster_process_starting{task=\"web\",instance=\"1\"} 0\n")
+	require.Contains(t, metrics, "taskmaster_process_start_retries{task=\"web\",instance=\"1\"} 1\n")
+	require.Contains(t, metrics, "taskmaster_process_restarts_total{task=\"web\",instance=\"1\"} 1\n")
+	require.Contains(t, metrics, "taskmaster_process_last_exit_status{task=\"web\",instance=\"1\"} 3\n")
+}
+
+func TestCollectReloads(t *testing.T) {
+	collector := newTestCollector(t)
+
+	collector.collect(events.Event{Type: events.RELOAD_SUCCEEDED})
+	collector.collect(events.Event{Type: events.RELOAD_FAILED, Error: "No task to run"})
+	collector.collect(events.Event{Type: events.RELOAD_SUCCEEDED})
+	metrics := write(collector)
+
+	require.Contains(t, metrics, "taskmaster_reloads_total{result=\"success\"} 2\n")
+	require.Contains(t, metrics, "taskmaster_reloads_total{result=\"failure\"} 1\n")
+}
@@ -0,0 +1,130 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/events"
+)
+
+// Kinds of notifications, as used in the `events` property of webhooks
+const (
+	UNEXPECTED_EXIT   = "unexpected-exit"
+	RETRIES_EXHAUSTED = "retries-exhausted"
+	FAILED_TO_START   = "failed-to-start"
+	RELOAD_FAILED     = "reload-failed"
+)
+
+// Longest time to wait for a webhook to answer a single request
+const REQUEST_TIMEOUT = 10 * time.Second
+
+// Body of the POST requests sent to webhooks
+type Payload struct {
+	Kind  string       `json:"kind"`
+	Event events.Event `json:"event"`
+}
+
+type NotificationError struct {
+	url   string
+	cause string
+}
+
+func (this NotificationError) Error() string {
+	return fmt.Sprintf("Failed to notify %s: %s", this.url, this.cause)
+}
+
+func newNotificationError(url, cause string) NotificationError {
+	return NotificationError{url, cause}
+}
+
+// Get the kind of notification to send for `event`, if any
+func kindOf(event events.Event) (string, bool) {
+	switch event.Type {
+	case "STOPPED_UNSUCCESSFULLY":
+		return UNEXPECTED_EXIT, !event.Requested
+	case "RETRIES_EXHAUSTED":
+		return RETRIES_EXHAUSTED, true
+	case "STARTING_ERROR", "RESTARTING_ERROR":
+		return FAILED_TO_START, true
+	case events.RELOAD_FAILED:
+		return RELOAD_FAILED, true
+	}
+	return "", false
+}
+
+// Send the events published on a broker to the webhooks of the current
+// configuration
+type Notifier struct {
+	manager config.Manager
+	client  *http.Client
+	report  func(string)
+}
+
+// Create a notifier for the events of `broker`, calling `report` with the
+// notifications that could not be sent.
+func NewNotifier(manager config.Manager, broker *events.Broker, report func(string)) *Notifier {
+	instance := &Notifier{
+		manager: manager,
+		client:  &http.Client{Timeout: REQUEST_TIMEOUT},
+		report:  report,
+	}
+	stream, _ := broker.SubscribeAll()
+	go func() {
+		for event := range stream {
+			instance.notify(event)
+		}
+	}()
+	return instance
+}
+
+func (this *Notifier) notify(event events.Event) {
+	kind, ok := kindOf(event)
+	if !ok {
+		return
+	}
+	for _, webhook := range this.manager.Get().Notifications {
+		if slices.Contains(webhook.Events, kind) {
+			go func() {
+				if err := this.send(webhook, Payload{kind, event}); err != nil {
+					this.report(err.Error())
+				}
+			}()
+		}
+	}
+}
+
+func (this *Notifier) post(url string, body []byte) error {
+	res, err := this.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// Post `payload` to the webhook, trying again `webhook.Retries` times,
+// waiting twice as long after each failure.
+func (this *Notifier) send(webhook config.Webhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return newNotificationError(webhook.Url, err.Error())
+	}
+	backoff := webhook.Backoff.Duration()
+	for attempt := uint(0); ; attempt++ {
+		if err = this.post(webhook.Url, body); err == nil {
+			return nil
+		} else if attempt == webhook.Retries {
+			return newNotificationError(webhook.Url, fmt.Sprintf("%s (after %d attempts)", err, attempt+1))
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"taskmaster/atom"
+	"taskmaster/config"
+	"taskmaster/events"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testManager struct {
+	config *config.Config
+}
+
+func (this testManager) Get() *config.Config                               { return this.config }
+func (this testManager) Subscribe(atom.AtomSubscriberFunc[*config.Config]) {}
+func (this testManager) Load() error                                       { return nil }
+func (this testManager) Plan() (config.ReloadPlan, error)                  { return config.ReloadPlan{}, nil }
+
+// Local stand-in for a webhook, failing the first `failures` requests
+func newTestWebhook(t *testing.T, failures int) (*httptest.Server, <-chan Payload) {
+	payloads := make(chan Payload, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Payload
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&payload))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		payloads <- payload
+	}))
+	t.Cleanup(server.Close)
+	return server, payloads
+}
+
+func newTestNotifier(webhooks ...config.Webhook) (*Notifier, *events.Broker, <-chan string) {
+	reports := make(chan string, 16)
+	broker := events.NewBroker(0)
+	manager := testManager{&config.Config{Notifications: webhooks}}
+	return NewNotifier(manager, broker, func(msg string) { reports <- msg }), broker, reports
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	select {
+	case value := <-ch:
+		return value
+	case <-time.After(time.Second):
+		require.FailNow(t, "timed out")
+	}
+	panic("unreachable")
+}
+
+func TestNotifyUnexpectedExit(t *testing.T) {
+	server, payloads := newTestWebhook(t, 0)
+	_, broker, _ := newTestNotifier(config.Webhook{Url: server.URL, Events: []string{UNEXPECTED_EXIT}})
+
+	exitCode := 1
+	broker.Publish(events.Event{Type: "STOPPED_UNSUCCESSFULLY", Task: "web", ExitCode: &exitCode, Requested: true})
+	broker.Publish(events.Event{Type: events.RELOAD_FAILED, Error: "No task to run"})
+	broker.Publish(events.Event{Type: "STOPPED_UNSUCCESSFULLY", Task: "worker", ExitCode: &exitCode})
+
+	payload := receive(t, payloads)
+	require.Equal(t, UNEXPECTED_EXIT, payload.Kind)
+	require.Equal(t, "worker", payload.Event.Task)
+	require.Equal(t, 1, *payload.Event.ExitCode)
+	require.Len(t, payloads, 0)
+}
+
+func TestNotifyRetry(t *testing.T) {
+	server, payloads := newTestWebhook(t, 2)
+	_, broker, reports := newTestNotifier(config.Webhook{Url: server.URL, Events: []string{RELOAD_FAILED}, Retries: 2, Backoff: 1})
+
+	broker.Publish(events.Event{Type: events.RELOAD_FAILED, Error: "No task to run"})
+
+	payload := receive(t, payloads)
+	require.Equal(t, RELOAD_FAILED, payload.Kind)
+	require.Equal(t, "No task to run", payload.Event.Error)
+	require.Len(t, reports, 0)
+}
+
+func TestNotifyGiveUp(t *testing.T) {
+	server, _ := newTestWebhook(t, 3)
+	_, broker, reports := newTestNotifier(config.Webhook{Url: server.URL, Events: []string{FAILED_TO_START}, Retries: 1, Backoff: 1})
+
+	broker.Publish(events.Event{Type: "STARTING_ERROR", Task: "web", Error: "exec: not found"})
+
+	require.Equal(
+		t,
+		"Failed to notify "+server.URL+": unexpected status 502 Bad Gateway (after 2 attempts)",
+		receive(t, reports),
+	)
+}
@@ -0,0 +1,213 @@
+package runners
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"taskmaster/events"
+)
+
+// Event listeners are tasks with a non-empty `events` property. They talk
+// with taskmaster over their stdin and stdout, one event at a time:
+//
+//   - the listener writes "READY\n" when it can handle an event
+//   - taskmaster writes a header line, followed by the event as JSON:
+//     "ver:1 serial:<sequence> pool:<task> eventname:<type> len:<length>\n"
+//   - the listener writes "RESULT <length>\n" followed by either "OK", or
+//     "FAIL" to get the event sent again, then "READY\n" for the next one.
+const LISTENER_PROTOCOL_VERSION = 1
+
+// Number of events waiting for a busy listener, older events are dropped
+// past this limit.
+const LISTENER_QUEUE_SIZE = 1024
+
+type ListenerProtocolError struct {
+	cause string
+}
+
+func (this ListenerProtocolError) Error() string {
+	return fmt.Sprintf("Event listener protocol error: %s", this.cause)
+}
+
+func newListenerProtocolError(cause string) ListenerProtocolError {
+	return ListenerProtocolError{cause}
+}
+
+const (
+	LISTENER_READY = "READY"
+	LISTENER_OK    = "OK"
+	LISTENER_FAIL  = "FAIL"
+)
+
+// Read the messages of a listener from its stdout, until it is closed or
+// the listener breaks the protocol.
+func readListenerReplies(stdout io.Reader, replies chan<- string, errs chan<- error) {
+	defer close(replies)
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == LISTENER_READY {
+			replies <- line
+		} else if length, isResult := strings.CutPrefix(line, "RESULT "); !isResult {
+			errs <- newListenerProtocolError(fmt.Sprintf("unexpected message %q", line))
+			return
+		} else if length, err := strconv.ParseUint(length, 10, 16); err != nil {
+			errs <- newListenerProtocolError(fmt.Sprintf("invalid result length %q", line))
+			return
+		} else {
+			result := make([]byte, length)
+			if _, err := io.ReadFull(reader, result); err != nil {
+				return
+			}
+			if string(result) != LISTENER_OK && string(result) != LISTENER_FAIL {
+				errs <- newListenerProtocolError(fmt.Sprintf("unexpected result %q", result))
+				return
+			}
+			replies <- string(result)
+		}
+	}
+}
+
+// An event waiting to be sent to a listener, with the serial number
+// identifying it for this listener
+type listenerEvent struct {
+	serial uint64
+	event  events.Event
+}
+
+func writeListenerEvent(stdin io.Writer, task string, pending listenerEvent) error {
+	payload, err := json.Marshal(pending.event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(
+		stdin,
+		"ver:%d serial:%d pool:%s eventname:%s len:%d\n%s",
+		LISTENER_PROTOCOL_VERSION, pending.serial, task, pending.event.Type, len(payload), payload,
+	)
+	return err
+}
+
+// Whether the listener asked for events of the same kind as `event`
+func (this *ProcessRunner) listensTo(event events.Event) bool {
+	switch {
+	case event.Type == events.LOG:
+		// Its own output would be sent back to it, endlessly
+		return slices.Contains(this.TaskConfig.Get().Events, "log") && event.Task != *this.TaskConfig.Get().Name
+	case len(event.Task) == 0:
+		return slices.Contains(this.TaskConfig.Get().Events, "reload")
+	default:
+		return slices.Contains(this.TaskConfig.Get().Events, "process")
+	}
+}
+
+// Send the events the task listens to on `stdin`, waiting for the listener
+// on `stdout`, until the process closes its stdout.
+func (this *ProcessRunner) runEventListener(stdin, stdout *os.File) {
+	stateEvents, unsubscribeState := Events.SubscribeAll()
+	defer unsubscribeState()
+	logEvents, unsubscribeLogs := LogEvents.SubscribeAll()
+	defer unsubscribeLogs()
+
+	this.serveEventListener(stdin, stdout, stateEvents, logEvents)
+}
+
+func (this *ProcessRunner) serveEventListener(stdin io.WriteCloser, stdout io.ReadCloser, stateEvents, logEvents <-chan events.Event) {
+	replies := make(chan string)
+	errs := make(chan error, 1)
+	go readListenerReplies(stdout, replies, errs)
+	defer func() {
+		stdin.Close()
+		stdout.Close()
+		// Let the reader end if it was blocked on a reply
+		for range replies {
+		}
+	}()
+
+	pending := []listenerEvent{}
+	serial := uint64(0)
+	queue := func(event events.Event) {
+		if this.listensTo(event) {
+			serial++
+			pending = append(pending, listenerEvent{serial, event})
+		}
+	}
+	ready, waitingResult := false, false
+	for {
+		if ready && len(pending) != 0 {
+			if err := writeListenerEvent(stdin, *this.TaskConfig.Get().Name, pending[0]); err != nil {
+				return
+			}
+			ready, waitingResult = false, true
+		}
+
+		select {
+		case event := <-stateEvents:
+			queue(event)
+		case event := <-logEvents:
+			queue(event)
+		case reply, ok := <-replies:
+			if !ok {
+				select {
+				case err := <-errs:
+					this.reportListenerError(err)
+				default:
+				}
+				return
+			}
+			switch {
+			case reply == LISTENER_READY && !waitingResult:
+				ready = true
+			case reply == LISTENER_READY:
+				this.reportListenerError(newListenerProtocolError("expected a result before READY"))
+				return
+			case !waitingResult:
+				this.reportListenerError(newListenerProtocolError("unexpected result, no event was sent"))
+				return
+			case reply == LISTENER_OK:
+				pending = pending[1:]
+				waitingResult = false
+			case reply == LISTENER_FAIL:
+				// Keep the event first in line, to send it again
+				waitingResult = false
+			}
+		}
+
+		// The event in flight (if any) must not be dropped
+		if len(pending) > LISTENER_QUEUE_SIZE {
+			pending = slices.Delete(pending, 1, len(pending)-LISTENER_QUEUE_SIZE+1)
+		}
+	}
+}
+
+func (this *ProcessRunner) reportListenerError(err error) {
+	Report(fmt.Sprintf("[%d - %d] %s", this.TaskId.Get(), this.Id, err))
+}
+
+// Get the stdin and stdout of an event listener process, and start sending
+// it events.
+func (this *ProcessRunner) pipeEventListener() (stdin, stdout *os.File, err error) {
+	stdin, eventsWriter, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	repliesReader, stdout, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		eventsWriter.Close()
+		return nil, nil, err
+	}
+	go this.runEventListener(eventsWriter, repliesReader)
+	return stdin, stdout, nil
+}
@@ -0,0 +1,202 @@
+package runners
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"taskmaster/atom"
+	"taskmaster/config"
+	"taskmaster/events"
+	"taskmaster/utils"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readReplies(stdout string) ([]string, error) {
+	replies := make(chan string)
+	errs := make(chan error, 1)
+	go readListenerReplies(strings.NewReader(stdout), replies, errs)
+
+	result := []string{}
+	for reply := range replies {
+		result = append(result, reply)
+	}
+	select {
+	case err := <-errs:
+		return result, err
+	default:
+		return result, nil
+	}
+}
+
+func TestReadListenerReplies(t *testing.T) {
+	replies, err := readReplies("READY\nRESULT 2\nOKREADY\nRESULT 4\nFAILREADY\n")
+
+	require.Nil(t, err)
+	require.Equal(t, []string{"READY", "OK", "READY", "FAIL", "READY"}, replies)
+}
+
+func TestReadListenerRepliesInvalid(t *testing.T) {
+	replies, err := readReplies("READY\nRESULT 5\nMAYBE")
+
+	require.Equal(t, []string{"READY"}, replies)
+	require.NotNil(t, err)
+	require.Equal(t, `Event listener protocol error: unexpected result "MAYBE"`, err.Error())
+
+	_, err = readReplies("hello\n")
+	require.NotNil(t, err)
+	require.Equal(t, `Event listener protocol error: unexpected message "hello"`, err.Error())
+}
+
+func TestWriteListenerEvent(t *testing.T) {
+	stdin := new(strings.Builder)
+
+	err := writeListenerEvent(stdin, "alerts", listenerEvent{3, events.Event{Type: events.RELOAD_FAILED, Error: "No task to run"}})
+
+	require.Nil(t, err)
+	header, payload, _ := strings.Cut(stdin.String(), "\n")
+	require.Equal(t, "ver:1 serial:3 pool:alerts eventname:RELOAD_FAILED len:"+strconv.Itoa(len(payload)), header)
+	require.Contains(t, payload, `"error":"No task to run"`)
+}
+
+// Both ends of a listener of process events served by a runner, on which
+// the test plays the listener
+type testListener struct {
+	events  chan events.Event
+	stdin   *bufio.Reader
+	stdout  io.WriteCloser
+	stopped chan struct{}
+}
+
+func serveTestListener(t *testing.T) *testListener {
+	runner := &ProcessRunner{
+		TaskConfig: atom.NewAtom(config.Task{Name: utils.New("listener"), Events: []string{"process"}}),
+		TaskId:     atom.NewAtom[uint](0),
+	}
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	listener := &testListener{
+		events:  make(chan events.Event),
+		stdin:   bufio.NewReader(stdinReader),
+		stdout:  stdoutWriter,
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		defer close(listener.stopped)
+		runner.serveEventListener(stdinWriter, stdoutReader, listener.events, make(chan events.Event))
+	}()
+	t.Cleanup(func() {
+		stdoutWriter.Close()
+		<-listener.stopped
+	})
+	return listener
+}
+
+func (this *testListener) reply(t *testing.T, messages ...string) {
+	_, err := io.WriteString(this.stdout, strings.Join(messages, ""))
+	require.Nil(t, err)
+}
+
+// Read the next event written by the runner, returning its serial number
+// and the event itself
+func (this *testListener) read(t *testing.T) (uint64, events.Event) {
+	header, err := this.stdin.ReadString('\n')
+	require.Nil(t, err)
+	var serial, length uint64
+	var pool, name string
+	_, err = fmt.Sscanf(header, "ver:1 serial:%d pool:%s eventname:%s len:%d\n", &serial, &pool, &name, &length)
+	require.Nil(t, err)
+	require.Equal(t, "listener", pool)
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(this.stdin, payload)
+	require.Nil(t, err)
+	event := events.Event{}
+	require.Nil(t, json.Unmarshal(payload, &event))
+	require.Equal(t, name, event.Type)
+	return serial, event
+}
+
+func TestEventListenerResendAfterFail(t *testing.T) {
+	listener := serveTestListener(t)
+	listener.events <- events.Event{Type: "STARTED", Task: "web", Sequence: 1}
+	listener.events <- events.Event{Type: "STOPPING", Task: "web", Sequence: 2}
+	// Not the kind of events the listener asked for
+	listener.events <- events.Event{Type: events.RELOAD_SUCCEEDED, Sequence: 3}
+	listener.reply(t, "READY\n")
+
+	serial, event := listener.read(t)
+	require.Equal(t, uint64(1), serial)
+	require.Equal(t, "STARTED", event.Type)
+	listener.reply(t, "RESULT 4\nFAIL", "READY\n")
+
+	serial, event = listener.read(t)
+	require.Equal(t, uint64(1), serial)
+	require.Equal(t, "STARTED", event.Type)
+	listener.reply(t, "RESULT 2\nOK", "READY\n")
+
+	serial, event = listener.read(t)
+	require.Equal(t, uint64(2), serial)
+	require.Equal(t, "STOPPING", event.Type)
+}
+
+func TestEventListenerQueueTrimming(t *testing.T) {
+	listener := serveTestListener(t)
+	listener.events <- events.Event{Type: "STARTED", Task: "web", Sequence: 1}
+	listener.reply(t, "READY\n")
+	serial, _ := listener.read(t)
+	require.Equal(t, uint64(1), serial)
+
+	// The event in flight is kept, along with the most recent ones
+	for sequence := range uint64(LISTENER_QUEUE_SIZE + 10) {
+		listener.events <- events.Event{Type: "STOPPING", Task: "web", Sequence: sequence + 2}
+	}
+	listener.reply(t, "RESULT 4\nFAIL", "READY\n")
+	serial, _ = listener.read(t)
+	require.Equal(t, uint64(1), serial)
+	listener.reply(t, "RESULT 2\nOK", "READY\n")
+
+	// The oldest events queued, from 2 to 12, were dropped
+	serial, event := listener.read(t)
+	require.Equal(t, uint64(13), serial)
+	require.Equal(t, serial, event.Sequence)
+}
+
+func TestEventListenerProtocolErrors(t *testing.T) {
+	logFile, err := os.Create(filepath.Join(t.TempDir(), "taskmaster.log"))
+	require.Nil(t, err)
+	previous, _ := TaskmasterLogFile.Set(logFile)
+	defer TaskmasterLogFile.Set(previous)
+
+	for _, test := range []struct {
+		replies []string
+		err     string
+	}{
+		{[]string{"RESULT 2\nOK"}, "unexpected result, no event was sent"},
+		{[]string{"READY\n", "READY\n"}, "expected a result before READY"},
+		{[]string{"RESULT 5\nMAYBE"}, `unexpected result "MAYBE"`},
+	} {
+		listener := serveTestListener(t)
+		if len(test.replies) == 2 {
+			listener.events <- events.Event{Type: "STARTED", Task: "web", Sequence: 1}
+			listener.reply(t, test.replies[0])
+			listener.read(t)
+		}
+		listener.reply(t, test.replies[len(test.replies)-1])
+
+		// The runner gives up on the listener, closing its stdin
+		<-listener.stopped
+		_, err := listener.stdin.ReadByte()
+		require.Equal(t, io.EOF, err)
+		content, err := os.ReadFile(logFile.Name())
+		require.Nil(t, err)
+		require.Contains(t, string(content), "[0 - 0] Event listener protocol error: "+test.err+"\n")
+	}
+}
@@ -4,16 +4,19 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"slices"
 	"sync"
 	"syscall"
 	"taskmaster/atom"
 	"taskmaster/config"
+	"taskmaster/events"
+	"taskmaster/logs"
 	"taskmaster/messages/helpers"
 	"taskmaster/messages/master/input"
 	"taskmaster/messages/master/output"
 	taskInput "taskmaster/messages/task/input"
 	taskOutput "taskmaster/messages/task/output"
-	"taskmaster/utils"
+	"taskmaster/shell"
 	"time"
 )
 
@@ -23,209 +26,434 @@ type MasterRunner struct {
 	Input  <-chan input.Message
 	Output chan<- output.Message
 
-	Tasks             []*TaskRunner
-	GlobalTasksOutput chan []taskOutput.Message
+	// Only changed by the loop of Run once it started, in the order of the
+	// tasks of the configuration
+	tasks []*taskLink
 
-	taskInputs  *[]chan taskInput.Message
-	taskOutputs *[]chan taskOutput.Message
-	stopSignal  chan StopSignal
+	tasksClosed *sync.WaitGroup
+	outputLinks *sync.WaitGroup
 
-	tasksClosed        *sync.WaitGroup
-	specificTaskClosed []*sync.WaitGroup
-	masterClosed       *sync.WaitGroup
-
-	shouldCloseOutput atom.Atom[bool]
-	globalOutputPipes []chan taskOutput.Message
+	// Configurations to apply, sent by the subscriber of the manager for the
+	// loop of Run to apply them
+	reloads chan reloadRequest
+	// Pending reloads, whose result is still to be sent
+	reloading *sync.WaitGroup
+	closed    chan struct{}
 
 	reloadSignal chan os.Signal
 }
 
+// A task and the channels linking it to the master
+type taskLink struct {
+	name   string
+	runner *TaskRunner
+	input  chan taskInput.Message
+	// Position of the task in the configuration, changed when tasks are
+	// added, removed or moved before it
+	id               atom.Atom[uint]
+	globalOutputPipe chan taskOutput.Message
+	closed           *sync.WaitGroup
+}
+
+// Error of a reload that was not applied, the tasks running with the previous
+// configuration
+type ReloadError struct {
+	task  string
+	cause error
+	// Error restoring a task that was already updated
+	restoreErr error
+}
+
+func (this ReloadError) Error() string {
+	message := this.cause.Error()
+	if len(this.task) != 0 {
+		message = fmt.Sprintf("task %s: %s", this.task, message)
+	}
+	if this.restoreErr != nil {
+		return fmt.Sprintf("%s, and restoring the previous configuration failed: %s", message, this.restoreErr)
+	}
+	return fmt.Sprintf("%s, the previous configuration is kept", message)
+}
+
+func newReloadError(task string, cause error) ReloadError {
+	return ReloadError{task: task, cause: cause}
+}
+
+type reloadRequest struct {
+	prevConf *config.Config
+	conf     *config.Config
+	result   chan<- error
+}
+
 type StopSignal struct{}
 
 var TaskmasterLogFile = atom.NewAtom[*os.File](nil)
 
-func NewMasterRunner(manager config.Manager, in <-chan input.Message, out chan<- output.Message) (*MasterRunner, error) {
-	conf := manager.Get()
+// Show `msg` in the shell, and write it to the taskmaster log file
+func Report(msg string) {
+	shell.Notify(msg)
+	fmt.Fprintf(TaskmasterLogFile.Get(), "%s: %s\n", time.Now().Format("06/01/02 15:04:05"), msg)
+}
+
+// Lifecycle events of every process
+var Events = events.NewBroker(EVENTS_HISTORY_SIZE)
+
+const EVENTS_HISTORY_SIZE = 1024
+
+// Lines written by every process, as they are written
+var LogEvents = events.NewBroker(0)
+
+// Recent output of every process
+var Logs = logs.NewStore(LOGS_TAIL_SIZE, func(task string, instance uint, line logs.Line) {
+	LogEvents.Publish(events.Event{
+		Time:     line.Time,
+		Type:     events.LOG,
+		Task:     task,
+		Instance: instance,
+		Stream:   line.Stream,
+		Text:     line.Text,
+	})
+})
+
+const LOGS_TAIL_SIZE = 200
 
-	taskInputs := make([]chan taskInput.Message, len(conf.Tasks))
-	taskOutputs := make([]chan taskOutput.Message, len(conf.Tasks))
+// Whether the processes started from now on write to pipes copying their
+// output to Logs, which the HTTP API and the listeners of log events read.
+// Otherwise they write directly to their log files, and keep running if
+// taskmaster dies.
+var TailOutput = atom.NewAtom(false)
 
-	globalTasksOutput := make(chan []taskOutput.Message)
+// Whether anything reads the output of the processes of `conf` as it is written
+func tailsOutput(conf *config.Config) bool {
+	if len(conf.Http) != 0 {
+		return true
+	}
+	for _, task := range conf.Tasks {
+		if slices.Contains(task.Events, "log") {
+			return true
+		}
+	}
+	return false
+}
 
-	tasks := make([]*TaskRunner, len(conf.Tasks))
+func NewMasterRunner(manager config.Manager, in <-chan input.Message, out chan<- output.Message) (*MasterRunner, error) {
+	conf := manager.Get()
 
 	instance := &MasterRunner{
-		ConfigManager:      manager,
-		Input:              in,
-		Output:             out,
-		Tasks:              tasks,
-		GlobalTasksOutput:  globalTasksOutput,
-		taskInputs:         &taskInputs,
-		taskOutputs:        &taskOutputs,
-		stopSignal:         make(chan StopSignal),
-		tasksClosed:        new(sync.WaitGroup),
-		specificTaskClosed: make([]*sync.WaitGroup, len(conf.Tasks)),
-		masterClosed:       new(sync.WaitGroup),
-		shouldCloseOutput:  atom.NewAtom(true),
-		globalOutputPipes:  make([]chan taskOutput.Message, len(taskOutputs)),
-		reloadSignal:       make(chan os.Signal, 16),
+		ConfigManager: manager,
+		Input:         in,
+		Output:        out,
+		tasks:         []*taskLink{},
+		tasksClosed:   new(sync.WaitGroup),
+		outputLinks:   new(sync.WaitGroup),
+		reloads:       make(chan reloadRequest),
+		reloading:     new(sync.WaitGroup),
+		closed:        make(chan struct{}),
+		reloadSignal:  make(chan os.Signal, 16),
 	}
-	if globalLogFile, err := os.OpenFile(fmt.Sprintf("%s/taskmaster_%s.log", conf.LogDir, time.Now().Format("060102_150405")), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666); err != nil {
+	if logFile, err := createLogFile(conf.LogDir); err != nil {
 		return nil, err
 	} else {
-		TaskmasterLogFile.Set(globalLogFile)
+		TaskmasterLogFile.Set(logFile)
 	}
+	reportWarnings(conf)
+	TailOutput.Set(tailsOutput(conf))
 
 	signal.Notify(instance.reloadSignal, syscall.SIGHUP)
 
-	instance.masterClosed.Add(1)
-
-	for i := range instance.Tasks {
-		instance.specificTaskClosed[i] = new(sync.WaitGroup)
-		taskInputs[i] = make(chan taskInput.Message)
-		taskOutputs[i] = make(chan taskOutput.Message)
-		if task, err := newTaskRunner(manager, uint(i), taskInputs[i], taskOutputs[i]); err != nil {
+	for i := range conf.Tasks {
+		if task, err := instance.newTask(conf, uint(i)); err != nil {
 			return nil, err
 		} else {
-			instance.Tasks[i] = task
+			instance.tasks = append(instance.tasks, task)
 		}
 	}
 
-	linkTaskOutput := func(idx uint, out <-chan taskOutput.Message) {
+	manager.Subscribe(func(prevConf, conf *config.Config) error {
+		result := make(chan error)
+		select {
+		case instance.reloads <- reloadRequest{prevConf, conf, result}:
+			return <-result
+		case <-instance.closed:
+			return nil
+		}
+	})
+	return instance, nil
+}
+
+// Create the file where the reports of taskmaster are written in `logDir`
+func createLogFile(logDir string) (*os.File, error) {
+	return os.OpenFile(fmt.Sprintf("%s/taskmaster_%s.log", logDir, time.Now().Format("060102_150405")), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o666)
+}
+
+// Create the runner of the task at `id` in `conf`, and forward its output to
+// the output of the master
+func (this *MasterRunner) newTask(conf *config.Config, id uint) (*taskLink, error) {
+	in := make(chan taskInput.Message)
+	out := make(chan taskOutput.Message)
+	runner, err := newTaskRunner(conf, id, in, out)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &taskLink{
+		name:             *conf.Tasks[id].Name,
+		runner:           runner,
+		input:            in,
+		id:               atom.NewAtom(id),
+		globalOutputPipe: make(chan taskOutput.Message),
+		closed:           new(sync.WaitGroup),
+	}
+
+	this.outputLinks.Add(1)
+	go func() {
+		defer this.outputLinks.Done()
+		defer close(task.globalOutputPipe)
 		for msg := range out {
 			switch msg.(type) {
 			case helpers.Global:
-				instance.globalOutputPipes[idx] <- msg
+				task.globalOutputPipe <- msg
+			case taskOutput.StartProcess:
+				this.Output <- output.NewStartProcess(task.name, msg.(taskOutput.StartProcess))
+			case taskOutput.StopProcess:
+				this.Output <- output.NewStopProcess(task.name, msg.(taskOutput.StopProcess))
+			case taskOutput.RestartProcess:
+				this.Output <- output.NewRestartProcess(task.name, msg.(taskOutput.RestartProcess))
 			}
 		}
+	}()
+	return task, nil
+}
+
+func (this *MasterRunner) runTask(task *taskLink) {
+	this.tasksClosed.Add(1)
+	task.closed.Add(1)
+	go func() {
+		task.runner.Run()
+		task.closed.Done()
+		this.tasksClosed.Done()
+	}()
+}
+
+// Stop every process of `task`
+func (this *MasterRunner) stopTask(task *taskLink) {
+	close(task.input)
+	task.closed.Wait()
+}
+
+// Find the task a process request designates, by name if it has one. The
+// id of the task is the one the response has to be sent with.
+func (this *MasterRunner) findTask(taskId uint, taskName string) (task *taskLink, id uint, err error) {
+	if len(taskName) == 0 {
+		if taskId >= uint(len(this.tasks)) {
+			return nil, 0, fmt.Errorf("invalid task id: %d", taskId)
+		}
+		return this.tasks[taskId], taskId, nil
 	}
+	for i, task := range this.tasks {
+		if task.name == taskName {
+			return task, uint(i), nil
+		}
+	}
+	return nil, 0, fmt.Errorf("unknown task: %s", taskName)
+}
+
+// Apply `conf` to the running `task`, which is at `id` in it
+func (this *MasterRunner) updateTask(task *taskLink, conf *config.Config, id uint) error {
+	result := make(chan error)
+	task.input <- taskInput.NewUpdate(conf, id, result)
+	return <-result
+}
+
+// Start and stop the instances of the task whose number the last update
+// changed
+func (this *MasterRunner) commitUpdate(task *taskLink) {
+	done := make(chan struct{})
+	task.input <- taskInput.NewCommitUpdate(done)
+	<-done
+}
 
-	for i, out := range taskOutputs {
-		instance.globalOutputPipes[i] = make(chan taskOutput.Message)
-		go linkTaskOutput(uint(i), out)
+// Apply the changes from `prevConf` to `conf`, the tasks being matched by
+// name: removed tasks are stopped and added ones are started, the other ones
+// keep running unless their changes require restarting them. What may fail
+// is done before stopping any task, and undone if anything fails for the
+// tasks to run with `prevConf` as before.
+func (this *MasterRunner) reload(prevConf, conf *config.Config) error {
+	current := map[string]*taskLink{}
+	for _, task := range this.tasks {
+		current[task.name] = task
 	}
+	plan := config.PlanReload(prevConf, conf)
 
-	manager.Subscribe(func(conf, prevConf *config.Config) error {
-		if conf.LogDir != prevConf.LogDir || len(conf.Tasks) != len(prevConf.Tasks) {
-			// Reload master
-			instance.forwardGlobalMessage(taskInput.NewShutdown())
-			instance.tasksClosed.Wait()
-			instance.shouldCloseOutput.Set(false)
-			instance.stopSignal <- StopSignal{}
-			instance.masterClosed.Wait()
-			if newInstance, err := NewMasterRunner(manager, in, out); err != nil {
-				return err
-			} else {
-				*instance = *newInstance
-				go instance.Run()
-			}
+	var logFile *os.File
+	if conf.LogDir != prevConf.LogDir {
+		if file, err := createLogFile(conf.LogDir); err != nil {
+			return newReloadError("", err)
 		} else {
-			// Check which task needs to be reloaded
-
-			for i := range conf.Tasks {
-				if conf.Tasks[i].String() != prevConf.Tasks[i].String() {
-					close(taskInputs[i])
-					instance.tasksClosed.Add(1)
-					instance.specificTaskClosed[i].Wait()
-					instance.specificTaskClosed[i].Add(1)
-					taskInputs[i] = make(chan taskInput.Message)
-					taskOutputs[i] = make(chan taskOutput.Message)
-					go linkTaskOutput(uint(i), taskOutputs[i])
-
-					if task, err := newTaskRunner(manager, uint(i), taskInputs[i], taskOutputs[i]); err != nil {
-						return err
-					} else {
-						instance.Tasks[i] = task
-						go func() {
-							task.Run()
-							instance.specificTaskClosed[i].Done()
-							instance.tasksClosed.Done()
-						}()
-					}
-				}
+			logFile = file
+		}
+	}
+
+	tasks := make([]*taskLink, len(conf.Tasks))
+	started, updated := []*taskLink{}, []*taskLink{}
+	rollback := func(task string, cause error) error {
+		err := newReloadError(task, cause)
+		for _, task := range started {
+			task.runner.discard()
+		}
+		for _, task := range updated {
+			if restoreErr := this.updateTask(task, prevConf, task.id.Get()); restoreErr != nil && err.restoreErr == nil {
+				err.restoreErr = restoreErr
 			}
 		}
-		return nil
-	})
+		if logFile != nil {
+			logFile.Close()
+		}
+		return err
+	}
 
-	go func() {
-		chunk := make([]taskOutput.Message, len(instance.globalOutputPipes))
-		for {
-			for i, ch := range instance.globalOutputPipes {
-				if value, ok := <-ch; !ok {
-					return
-				} else {
-					chunk[i] = value
-				}
+	for i, change := range plan.Tasks[:len(conf.Tasks)] {
+		id := uint(i)
+		task, running := current[change.Name]
+		switch {
+
+		case !running || change.Action == config.TASK_RESTARTED:
+			if task, err := this.newTask(conf, id); err != nil {
+				return rollback(change.Name, err)
+			} else {
+				started = append(started, task)
+				tasks[i] = task
 			}
 
-			globalTasksOutput <- chunk
+		case task.id.Get() != id || change.Action != config.TASK_UNCHANGED:
+			// The processes that keep running are not restarted
+			if err := this.updateTask(task, conf, id); err != nil {
+				return rollback(change.Name, err)
+			}
+			updated = append(updated, task)
+			tasks[i] = task
+
+		default:
+			tasks[i] = task
+
 		}
-	}()
-	return instance, nil
+	}
+
+	for _, change := range plan.Tasks {
+		if change.Action == config.TASK_REMOVED || change.Action == config.TASK_RESTARTED {
+			this.stopTask(current[change.Name])
+		}
+	}
+	for i, task := range tasks {
+		task.id.Set(uint(i))
+	}
+	TailOutput.Set(tailsOutput(conf))
+	for _, task := range updated {
+		this.commitUpdate(task)
+	}
+	for _, task := range started {
+		this.runTask(task)
+	}
+	if logFile != nil {
+		if old, _ := TaskmasterLogFile.Set(logFile); old != nil {
+			old.Close()
+		}
+	}
+	this.tasks = tasks
+	reportWarnings(conf)
+	return nil
+}
+
+func reportWarnings(conf *config.Config) {
+	for _, warning := range conf.Warnings() {
+		Report(fmt.Sprintf("warning: %s", warning))
+	}
 }
 
 func (this *MasterRunner) close() {
-	for _, ch := range *this.taskInputs {
-		close(ch)
+	close(this.closed)
+	for _, task := range this.tasks {
+		close(task.input)
 	}
-	close(this.stopSignal)
 	this.tasksClosed.Wait()
-	for _, ch := range this.globalOutputPipes {
-		close(ch)
-	}
-	close(this.GlobalTasksOutput)
+	this.outputLinks.Wait()
+	this.reloading.Wait()
 	TaskmasterLogFile.Get().Close()
 	TaskmasterLogFile.Set(nil)
-	if this.shouldCloseOutput.Get() {
-		close(this.Output)
+	this.Output <- output.NewShutdown()
+	close(this.Output)
+}
+
+// Reload the configuration the same way as when taskmaster receives SIGHUP,
+// unless a reload is already pending
+func (this *MasterRunner) Reload() {
+	select {
+	case this.reloadSignal <- syscall.SIGHUP:
+	default:
 	}
-	this.masterClosed.Done()
 }
 
 func (this *MasterRunner) forwardGlobalMessage(message interface {
 	helpers.Global
 	taskInput.Message
 }) {
-	for _, ch := range *this.taskInputs {
-		ch <- message
+	for _, task := range this.tasks {
+		task.input <- message
 	}
 }
 
 func (this *MasterRunner) Run() {
 	defer this.close()
-	for i, task := range this.Tasks {
-		this.tasksClosed.Add(1)
-		this.specificTaskClosed[i].Add(1)
-		go func() {
-			task.Run()
-			this.specificTaskClosed[i].Done()
-			this.tasksClosed.Done()
-		}()
+	for _, task := range this.tasks {
+		this.runTask(task)
 	}
 
-	go func() {
-		for global := range this.GlobalTasksOutput {
-			switch global[0].(type) {
-
-			case taskOutput.Status:
-				this.Output <- output.NewStatus(
-					utils.Transform(
-						global,
-						func(i int, elem *taskOutput.Message) taskOutput.Status {
-							return (*elem).(taskOutput.Status)
-						},
-					),
-				)
+	// Reloads run one at a time, the ones asked for meanwhile wait for the
+	// current one to end. Only the reloads asked for by clients are
+	// answered, the others (SIGHUP, watched files) are reported.
+	reloadDone := make(chan struct{})
+	reloadConfig := func(answer bool) {
+		defer this.reloading.Done()
+		if err := this.ConfigManager.Load(); err != nil {
+			Events.Publish(events.Event{Type: events.RELOAD_FAILED, Error: err.Error()})
+			if answer {
+				this.Output <- output.NewReloadFailure(err.Error())
+			} else {
+				Report(fmt.Sprintf("failed to reload the configuration: %s", err))
+			}
+		} else {
+			Events.Publish(events.Event{Type: events.RELOAD_SUCCEEDED})
+			if answer {
+				this.Output <- output.NewReloadSuccess()
+			} else {
+				Report("configuration reloaded")
 			}
 		}
-	}()
+		select {
+		case reloadDone <- struct{}{}:
+		case <-this.closed:
+		}
+	}
+	reloadRunning, pendingReloads := false, []bool{}
+	queueReload := func(answer bool) {
+		switch {
+		case !reloadRunning:
+			reloadRunning = true
+			this.reloading.Add(1)
+			go reloadConfig(answer)
+		// A reload that is not answered is enough for all of them, it
+		// reads the files after they changed
+		case answer || !slices.Contains(pendingReloads, false):
+			pendingReloads = append(pendingReloads, answer)
+		}
+	}
 
-	reloadConfig := func() {
-		if err := this.ConfigManager.Load(); err != nil {
-			this.Output <- output.NewReloadFailure(err.Error())
+	planReload := func() {
+		defer this.reloading.Done()
+		if plan, err := this.ConfigManager.Plan(); err != nil {
+			this.Output <- output.NewPlanReloadFailure(err.Error())
 		} else {
-			this.Output <- output.NewReloadSuccess()
+			this.Output <- output.NewPlanReloadSuccess(plan.Tasks)
 		}
 	}
 
@@ -233,10 +461,18 @@ func (this *MasterRunner) Run() {
 		select {
 
 		case <-this.reloadSignal:
-			go reloadConfig()
+			queueReload(false)
+
+		case <-reloadDone:
+			reloadRunning = false
+			if len(pendingReloads) != 0 {
+				answer := pendingReloads[0]
+				pendingReloads = pendingReloads[1:]
+				queueReload(answer)
+			}
 
-		case <-this.stopSignal:
-			return
+		case req := <-this.reloads:
+			req.result <- this.reload(req.prevConf, req.conf)
 
 		case req, ok := <-this.Input:
 			if !ok {
@@ -247,34 +483,46 @@ func (this *MasterRunner) Run() {
 
 			case input.Status:
 				this.forwardGlobalMessage(taskInput.NewStatus())
+				statuses := make([]taskOutput.Status, len(this.tasks))
+				for i, task := range this.tasks {
+					statuses[i] = (<-task.globalOutputPipe).(taskOutput.Status)
+				}
+				this.Output <- output.NewStatus(statuses)
 
 			case input.StartProcess:
 				req := req.(input.StartProcess)
-				if req.TaskId() >= uint(len(this.Tasks)) {
-					break
+				if task, id, err := this.findTask(req.TaskId(), req.TaskName()); err != nil {
+					this.Output <- output.NewStartProcessFailure(req.TaskId(), req.TaskName(), req.ProcessId(), err.Error())
+				} else {
+					task.input <- taskInput.NewStartProcess(id, req.ProcessId())
 				}
-				(*this.taskInputs)[req.TaskId()] <- taskInput.NewStartProcess(req.ProcessId())
 
 			case input.StopProcess:
 				req := req.(input.StopProcess)
-				if req.TaskId() >= uint(len(this.Tasks)) {
-					break
+				if task, id, err := this.findTask(req.TaskId(), req.TaskName()); err != nil {
+					this.Output <- output.NewStopProcessFailure(req.TaskId(), req.TaskName(), req.ProcessId(), err.Error())
+				} else {
+					task.input <- taskInput.NewStopProcess(id, req.ProcessId())
 				}
-				(*this.taskInputs)[req.TaskId()] <- taskInput.NewStopProcess(req.ProcessId())
 
 			case input.RestartProcess:
 				req := req.(input.RestartProcess)
-				if req.TaskId() >= uint(len(this.Tasks)) {
-					break
+				if task, id, err := this.findTask(req.TaskId(), req.TaskName()); err != nil {
+					this.Output <- output.NewRestartProcessFailure(req.TaskId(), req.TaskName(), req.ProcessId(), err.Error())
+				} else {
+					task.input <- taskInput.NewRestartProcess(id, req.ProcessId())
 				}
-				(*this.taskInputs)[req.TaskId()] <- taskInput.NewRestartProcess(req.ProcessId())
 
 			case input.Shutdown:
 				this.forwardGlobalMessage(taskInput.NewShutdown())
 				return
 
 			case input.Reload:
-				go reloadConfig()
+				queueReload(true)
+
+			case input.PlanReload:
+				this.reloading.Add(1)
+				go planReload()
 
 			default:
 				this.Output <- output.NewBadRequest()
@@ -0,0 +1,409 @@
+package runners
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"taskmaster/config"
+	"taskmaster/messages/master/input"
+	"taskmaster/messages/master/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, path, logDir, tasks string) {
+	content := `{"logDir": "` + logDir + `", "tasks": [` + tasks + `]}`
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// A master run until the end of a test
+type testMaster struct {
+	*MasterRunner
+	in  chan<- input.Message
+	out <-chan output.Message
+}
+
+func runMaster(t *testing.T, manager config.Manager) *testMaster {
+	in, out := make(chan input.Message), make(chan output.Message)
+	runner, err := NewMasterRunner(manager, in, out)
+	require.Nil(t, err)
+	go runner.Run()
+	t.Cleanup(func() {
+		in <- input.NewShutdown()
+		for range out {
+		}
+	})
+	return &testMaster{runner, in, out}
+}
+
+// Get the status of the tasks, skipping the other messages sent meanwhile
+func (this *testMaster) status() output.Status {
+	this.in <- input.NewStatus()
+	for res := range this.out {
+		if status, ok := res.(output.Status); ok {
+			return status
+		}
+	}
+	return nil
+}
+
+// Pids of the processes started since the creation of the recorder, in the
+// order they started, by task name and instance
+type startRecorder struct {
+	lock *sync.Mutex
+	pids map[string][]int
+}
+
+func recordStarts(t *testing.T) *startRecorder {
+	instance := &startRecorder{new(sync.Mutex), map[string][]int{}}
+	stream, unsubscribe := Events.SubscribeAll()
+	t.Cleanup(unsubscribe)
+	go func() {
+		for event := range stream {
+			if event.Type == "STARTED" {
+				instance.lock.Lock()
+				key := fmt.Sprintf("%s/%d", event.Task, event.Instance)
+				instance.pids[key] = append(instance.pids[key], event.Pid)
+				instance.lock.Unlock()
+			}
+		}
+	}()
+	return instance
+}
+
+func (this *startRecorder) get() map[string][]int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	result := map[string][]int{}
+	for key, pids := range this.pids {
+		result[key] = append([]int{}, pids...)
+	}
+	return result
+}
+
+// Wait for the processes named "task/instance" in `keys` to be started
+// `count` times in total
+func (this *startRecorder) waitStarts(t *testing.T, count int, keys ...string) map[string][]int {
+	require.Eventually(t, func() bool {
+		pids := this.get()
+		for _, key := range keys {
+			if len(pids[key]) != count {
+				return false
+			}
+		}
+		return true
+	}, 2*time.Second, 10*time.Millisecond)
+	return this.get()
+}
+
+// Describe the status of the tasks as "id name: process states"
+func describeStatus(status output.Status) []string {
+	result := []string{}
+	for _, task := range status.Tasks() {
+		description := fmt.Sprintf("%d %s:", task.TaskId(), task.Name())
+		for _, process := range task.Processes() {
+			description += " " + process.Value()
+		}
+		result = append(result, description)
+	}
+	return result
+}
+
+func TestReloadRollback(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `
+		{"name": "a", "command": "/bin/sleep", "arguments": ["60"]},
+		{"name": "b", "command": "/bin/sleep", "arguments": ["60"]}
+	`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	previous := manager.Get()
+
+	master := runMaster(t, manager)
+
+	writeConfig(t, path, dir, `
+		{"name": "a", "command": "/bin/sleep", "arguments": ["60"], "instances": 2},
+		{"name": "c", "command": "/bin/sleep", "arguments": ["60"], "stdout": "redirect", "stdoutLogFile": "/nonexistent/c.log"}
+	`)
+	err = manager.Load()
+	require.NotNil(t, err)
+	require.Equal(t, "task c: open /nonexistent/c.log: no such file or directory, the previous configuration is kept", err.Error())
+	require.Same(t, previous, manager.Get())
+
+	status := master.status()
+	require.Len(t, status.Tasks(), 2)
+	require.Equal(t, "a", status.Tasks()[0].Name())
+	require.Len(t, status.Tasks()[0].Processes(), 1)
+	require.Equal(t, "b", status.Tasks()[1].Name())
+}
+
+func TestReloadRollbackScaleDown(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `
+		{"name": "a", "command": "/bin/sleep", "arguments": ["60"], "instances": 2},
+		{"name": "b", "command": "/bin/sleep", "arguments": ["60"]}
+	`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	starts := recordStarts(t)
+	master := runMaster(t, manager)
+	before := starts.waitStarts(t, 1, "a/0", "a/1", "b/0")
+
+	writeConfig(t, path, dir, `
+		{"name": "a", "command": "/bin/sleep", "arguments": ["60"], "instances": 1},
+		{"name": "c", "command": "/bin/sleep", "arguments": ["60"], "stdout": "redirect", "stdoutLogFile": "/nonexistent/c.log"}
+	`)
+	err = manager.Load()
+	require.NotNil(t, err)
+	require.Equal(t, "task c: open /nonexistent/c.log: no such file or directory, the previous configuration is kept", err.Error())
+
+	// The instance to stop was still running when the reload failed
+	require.Equal(t, []string{"0 a: RUNNING RUNNING", "1 b: RUNNING"}, describeStatus(master.status()))
+	require.Equal(t, before, starts.get())
+}
+
+func TestOutputTailing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `
+		{"name": "direct", "command": "/bin/echo", "arguments": ["hello"], "restart": "never", "stdout": "redirect", "stdoutLogFile": "direct.log"}
+	`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	runMaster(t, manager)
+
+	// Without the HTTP API nor listeners of log events, the process writes
+	// to its log file directly
+	require.Eventually(t, func() bool {
+		content, _ := os.ReadFile(filepath.Join(dir, "direct.log"))
+		return string(content) == "hello\n"
+	}, time.Second, 10*time.Millisecond)
+	require.Len(t, Logs.Get("direct", 0).Lines(0), 0)
+
+	content := `{"logDir": "` + dir + `", "http": "127.0.0.1:0", "tasks": [
+		{"name": "tailed", "command": "/bin/echo", "arguments": ["hello"], "restart": "never", "stdout": "redirect", "stdoutLogFile": "tailed.log"}
+	]}`
+	require.Nil(t, os.WriteFile(path, []byte(content), 0o644))
+	tailed := len(Logs.Get("tailed", 0).Lines(0))
+	require.Nil(t, manager.Load())
+
+	require.Eventually(t, func() bool {
+		lines := Logs.Get("tailed", 0).Lines(0)
+		return len(lines) == tailed+1 && lines[tailed].Text == "hello"
+	}, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		content, _ := os.ReadFile(filepath.Join(dir, "tailed.log"))
+		return string(content) == "hello\n"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCommandInTaskPath(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, os.Mkdir(filepath.Join(dir, "bin"), 0o755))
+	require.Nil(t, os.WriteFile(filepath.Join(dir, "bin", "greet"), []byte("#!/bin/sh\necho \"$1\"\n"), 0o755))
+	path := filepath.Join(dir, "tmconfig.json")
+	// The command is found with the PATH of the task, relative to its
+	// working directory
+	writeConfig(t, path, dir, `
+		{"name": "greet", "command": "greet", "arguments": ["hello"], "restart": "never", "workingDirectory": "`+dir+`",
+		 "environment": {"PATH": "bin"}, "stdout": "redirect", "stdoutLogFile": "greet.log"}
+	`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	runMaster(t, manager)
+
+	require.Eventually(t, func() bool {
+		content, _ := os.ReadFile(filepath.Join(dir, "greet.log"))
+		return string(content) == "hello\n"
+	}, time.Second, 10*time.Millisecond)
+}
+
+// Manager counting the loads of the configuration that overlapped another one
+type countingManager struct {
+	config.Manager
+	loading, overlaps *atomic.Int32
+}
+
+func (this countingManager) Load() error {
+	if this.loading.Add(1) > 1 {
+		this.overlaps.Add(1)
+	}
+	defer this.loading.Add(-1)
+	time.Sleep(20 * time.Millisecond)
+	return this.Manager.Load()
+}
+
+func TestReloadsAreSerialized(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `{"name": "a", "command": "/bin/sleep", "arguments": ["60"]}`)
+	parent, err := config.NewManager(path)
+	require.Nil(t, err)
+	manager := countingManager{parent, new(atomic.Int32), new(atomic.Int32)}
+	master := runMaster(t, manager)
+
+	// Only the reloads asked for by clients are answered
+	for range 3 {
+		master.Reload()
+		master.in <- input.NewReload()
+	}
+	for range 3 {
+		_, ok := (<-master.out).(output.ReloadSuccess)
+		require.True(t, ok)
+	}
+	require.Eventually(t, func() bool { return manager.loading.Load() == 0 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(0), manager.overlaps.Load())
+	require.Len(t, master.status().Tasks(), 1)
+}
+
+func TestReloadUpdatesLiveProperties(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `{"name": "live", "command": "/bin/sleep", "arguments": ["60"], "instances": 2, "restartAttempts": 3}`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	starts := recordStarts(t)
+	master := runMaster(t, manager)
+	before := starts.waitStarts(t, 1, "live/0", "live/1")
+
+	writeConfig(t, path, dir, `{"name": "live", "command": "/bin/sleep", "arguments": ["60"], "instances": 3, "restartAttempts": 5}`)
+	require.Nil(t, manager.Load())
+	after := starts.waitStarts(t, 1, "live/0", "live/1", "live/2")
+	require.Equal(t, before["live/0"], after["live/0"])
+	require.Equal(t, before["live/1"], after["live/1"])
+
+	writeConfig(t, path, dir, `{"name": "live", "command": "/bin/sleep", "arguments": ["60"], "instances": 1, "restartAttempts": 5}`)
+	require.Nil(t, manager.Load())
+	require.Equal(t, []string{"0 live: RUNNING"}, describeStatus(master.status()))
+	require.Equal(t, before["live/0"], starts.get()["live/0"])
+}
+
+func TestReloadRestartsInstanceCountTemplates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	writeConfig(t, path, dir, `{"name": "counted", "command": "/bin/sh", "arguments": ["-c", "sleep 60", "{{.InstanceCount}}"]}`)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	starts := recordStarts(t)
+	runMaster(t, manager)
+	starts.waitStarts(t, 1, "counted/0")
+
+	// The first process is given the new number of instances too
+	writeConfig(t, path, dir, `{"name": "counted", "command": "/bin/sh", "arguments": ["-c", "sleep 60", "{{.InstanceCount}}"], "instances": 2}`)
+	require.Nil(t, manager.Load())
+	starts.waitStarts(t, 1, "counted/1")
+	starts.waitStarts(t, 2, "counted/0")
+}
+
+func TestReloadKeepsUntouchedTasks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	task := func(name string) string {
+		return `{"name": "` + name + `", "command": "/bin/sleep", "arguments": ["60"]}`
+	}
+	writeConfig(t, path, dir, task("first")+","+task("second")+","+task("third"))
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	starts := recordStarts(t)
+	master := runMaster(t, manager)
+	before := starts.waitStarts(t, 1, "first/0", "second/0", "third/0")
+
+	// Removing a task renumbers the next ones
+	writeConfig(t, path, dir, task("second")+","+task("third"))
+	require.Nil(t, manager.Load())
+	require.Equal(t, []string{"0 second: RUNNING", "1 third: RUNNING"}, describeStatus(master.status()))
+
+	// Adding a task before the others
+	writeConfig(t, path, dir, task("fourth")+","+task("second")+","+task("third"))
+	require.Nil(t, manager.Load())
+	starts.waitStarts(t, 1, "fourth/0")
+	require.Equal(t, []string{"0 fourth: RUNNING", "1 second: RUNNING", "2 third: RUNNING"}, describeStatus(master.status()))
+
+	// Reordering the tasks
+	writeConfig(t, path, dir, task("third")+","+task("fourth")+","+task("second"))
+	require.Nil(t, manager.Load())
+	require.Equal(t, []string{"0 third: RUNNING", "1 fourth: RUNNING", "2 second: RUNNING"}, describeStatus(master.status()))
+
+	after := starts.get()
+	require.Equal(t, before["second/0"], after["second/0"])
+	require.Equal(t, before["third/0"], after["third/0"])
+	require.Len(t, after["fourth/0"], 1)
+
+	// Requests reach the tasks by their new position
+	master.in <- input.NewStopProcess(2, 0)
+	for res := range master.out {
+		if _, ok := res.(output.StopProcess); ok {
+			break
+		}
+	}
+	require.Eventually(t, func() bool {
+		return describeStatus(master.status())[2] != "2 second: RUNNING"
+	}, 2*time.Second, 10*time.Millisecond)
+	require.Equal(t, "0 third: RUNNING", describeStatus(master.status())[0])
+}
+
+// Wait for the response to a process request, skipping the other messages
+func (this *testMaster) processResponse() interface {
+	output.Message
+	TaskId() uint
+	TaskName() string
+	ProcessId() uint
+} {
+	for res := range this.out {
+		switch res := res.(type) {
+		case output.StartProcess:
+			return res
+		case output.StopProcess:
+			return res
+		case output.RestartProcess:
+			return res
+		}
+	}
+	return nil
+}
+
+func TestProcessRequestsAcrossReorders(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tmconfig.json")
+	// Stopping it takes the whole stop time, for the reload to happen
+	// while it is stopped
+	slow := `{"name": "slow", "command": "/bin/sh", "arguments": ["-c", "trap '' TERM; sleep 60 & wait"], "stopTime": 300}`
+	other := `{"name": "other", "command": "/bin/sleep", "arguments": ["60"]}`
+	writeConfig(t, path, dir, slow+","+other)
+	manager, err := config.NewManager(path)
+	require.Nil(t, err)
+	starts := recordStarts(t)
+	master := runMaster(t, manager)
+	starts.waitStarts(t, 1, "slow/0", "other/0")
+
+	master.in <- input.NewStopProcess(0, 0)
+	writeConfig(t, path, dir, other+","+slow)
+	loaded := make(chan error)
+	go func() { loaded <- manager.Load() }()
+
+	// Answered with the id of the request even if the task moved
+	res := master.processResponse()
+	require.Implements(t, (*output.StopProcessSuccess)(nil), res)
+	require.Equal(t, uint(0), res.TaskId())
+	require.Equal(t, "slow", res.TaskName())
+	require.Nil(t, <-loaded)
+
+	// Requests by name reach the task wherever it is
+	master.in <- input.NewStopProcessByName("slow", 0)
+	res = master.processResponse()
+	require.Equal(t, uint(1), res.TaskId())
+	require.Equal(t, "slow", res.TaskName())
+
+	master.in <- input.NewStopProcessByName("missing", 0)
+	res = master.processResponse()
+	require.Implements(t, (*output.StopProcessFailure)(nil), res)
+	require.Equal(t, "unknown task: missing", res.(output.StopProcessFailure).Reason())
+	require.Equal(t, "0 other: RUNNING", describeStatus(master.status())[0])
+}
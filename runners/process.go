@@ -6,14 +6,16 @@ import (
 	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
 	"taskmaster/atom"
 	"taskmaster/config"
+	"taskmaster/events"
 	"taskmaster/messages/process/input"
 	"taskmaster/messages/process/output"
-	"taskmaster/shell"
 	"taskmaster/utils"
 )
 
@@ -120,13 +122,44 @@ const (
 	STOPPING
 	STOPPED_SUCCESSFULLY
 	STOPPED_UNSUCCESSFULLY
+	RETRIES_EXHAUSTED
 )
 
+var PROCESS_RESPONSE_NAMES = map[ProcessResponse]string{
+	STARTING:               "STARTING",
+	STARTING_ERROR:         "STARTING_ERROR",
+	RESTARTING_ERROR:       "RESTARTING_ERROR",
+	RESTARTING:             "RESTARTING",
+	RETRYING:               "RETRYING",
+	STARTED:                "STARTED",
+	STOPPED_EARLY:          "STOPPED_EARLY",
+	STOPPING:               "STOPPING",
+	STOPPED_SUCCESSFULLY:   "STOPPED_SUCCESSFULLY",
+	STOPPED_UNSUCCESSFULLY: "STOPPED_UNSUCCESSFULLY",
+	RETRIES_EXHAUSTED:      "RETRIES_EXHAUSTED",
+}
+
+func (this ProcessResponse) String() string {
+	return PROCESS_RESPONSE_NAMES[this]
+}
+
+// A ProcessResponse along with the state of the process when it happened
+type processNotification struct {
+	response  ProcessResponse
+	pid       int
+	startTime *time.Time
+	exitCode  *int
+	requested bool
+	retries   uint
+}
+
 type ProcessRunner struct {
-	ConfigManager config.Manager
-	TaskConfig    config.Task
+	// Updated with the live properties of the task when it is reloaded
+	TaskConfig atom.Atom[config.Task]
 
-	TaskId uint
+	// Position of the task in the configuration, which changes when tasks
+	// before it are added or removed
+	TaskId atom.Atom[uint]
 	Id     uint
 
 	StdoutLogFile *os.File
@@ -137,14 +170,34 @@ type ProcessRunner struct {
 
 	State *processState
 
-	internalOutput chan ProcessResponse
+	internalOutput chan processNotification
 	commandErrors  chan error
 	stopSignal     chan StopSignal
 	startInterrupt chan startInterrupt
+	outputCopies   *sync.WaitGroup
 }
 
 type startInterrupt struct{}
 
+// Report `response` along with the current state of the process
+func (this *ProcessRunner) notify(response ProcessResponse) {
+	notification := processNotification{
+		response:  response,
+		startTime: this.State.startTime.Get(),
+		retries:   this.State.startRetries.Get(),
+	}
+	if command := this.State.command.Get(); command != nil && command.Process != nil {
+		notification.pid = command.Process.Pid
+	}
+	if response == STOPPED_SUCCESSFULLY || response == STOPPED_UNSUCCESSFULLY {
+		notification.exitCode = this.State.exitStatus.Get()
+		notification.requested = this.State.userStopTime.Get() != nil ||
+			this.State.isRestarting.Get() ||
+			this.State.hasBeenShutdown.Get()
+	}
+	this.internalOutput <- notification
+}
+
 func (this *ProcessRunner) close() {
 	this.State.hasBeenShutdown.Set(true)
 
@@ -153,21 +206,102 @@ func (this *ProcessRunner) close() {
 		<-this.stopSignal
 	}
 	close(this.Output)
-	this.StdoutLogFile.Close()
-	this.StderrLogFile.Close()
+	// The output of the process may still be copied to its log files, by
+	// processes it left behind
+	go func() {
+		this.outputCopies.Wait()
+		closeLogFile(this.StdoutLogFile)
+		closeLogFile(this.StderrLogFile)
+	}()
 }
 
-func (this *ProcessRunner) initCommand() {
-	command := exec.Command(*this.TaskConfig.Command, this.TaskConfig.Arguments...)
+// Release what a runner that never ran holds
+func (this *ProcessRunner) discard() {
+	close(this.Output)
+	closeLogFile(this.StdoutLogFile)
+	closeLogFile(this.StderrLogFile)
+}
 
-	for k, v := range maps.All(this.TaskConfig.Environment) {
+// Get the file the process writes on `stream`: `logFile` itself, or a pipe
+// copying its content to `logFile` and to the recent output of the process
+// if TailOutput is set.
+func (this *ProcessRunner) pipeOutput(stream string, logFile *os.File) (file *os.File, isPipe bool, err error) {
+	if !TailOutput.Get() {
+		return logFile, false, nil
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, false, err
+	}
+	buffer := Logs.Get(*this.TaskConfig.Get().Name, this.Id).Writer(stream)
+	this.outputCopies.Add(1)
+	go func() {
+		defer this.outputCopies.Done()
+		defer reader.Close()
+		chunk := make([]byte, 4096)
+		for {
+			n, err := reader.Read(chunk)
+			if n > 0 {
+				logFile.Write(chunk[:n])
+				buffer.Write(chunk[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return writer, true, nil
+}
+
+// Prepare the command of the process, returning the ends of the pipes it is
+// given, to close once it is started.
+func (this *ProcessRunner) initCommand() (pipes []*os.File, err error) {
+	taskConf := this.TaskConfig.Get()
+	path, err := taskConf.LookPath()
+	if err != nil {
+		return nil, err
+	}
+	command := exec.Command(path, taskConf.Arguments...)
+	command.Args[0] = *taskConf.Command
+
+	for k, v := range maps.All(taskConf.Environment) {
 		command.Env = append(command.Env, fmt.Sprintf("%s=%s", k, v))
 	}
 
-	command.Dir = this.TaskConfig.WorkingDirectory
-	command.Stdout = this.StdoutLogFile
-	command.Stderr = this.StderrLogFile
+	command.Dir = taskConf.WorkingDirectory
+	defer func() {
+		if err != nil {
+			for _, pipe := range pipes {
+				pipe.Close()
+			}
+		}
+	}()
+	stderr, isPipe, err := this.pipeOutput("stderr", this.StderrLogFile)
+	if err != nil {
+		return pipes, err
+	} else if isPipe {
+		pipes = append(pipes, stderr)
+	}
+	command.Stderr = stderr
+	if len(taskConf.Events) != 0 {
+		// The stdout of event listeners is reserved for the protocol
+		stdin, stdout, err := this.pipeEventListener()
+		if err != nil {
+			return pipes, err
+		}
+		command.Stdin, command.Stdout = stdin, stdout
+		pipes = append(pipes, stdin, stdout)
+	} else {
+		stdout, isPipe, err := this.pipeOutput("stdout", this.StdoutLogFile)
+		if err != nil {
+			return pipes, err
+		} else if isPipe {
+			pipes = append(pipes, stdout)
+		}
+		command.Stdout = stdout
+	}
 	this.State.command.Set(command)
+	return pipes, nil
 }
 
 type OutputSource int
@@ -177,7 +311,7 @@ const (
 	STDERR
 )
 
-func getLogFile(source OutputSource, logConfig string, conf *config.Config, taskId, processId uint) (*os.File, error) {
+func getLogFile(source OutputSource, taskConf *config.Task, conf *config.Config, taskId, processId uint) (*os.File, error) {
 	getSourceName := func() string {
 		switch source {
 		case STDOUT:
@@ -188,6 +322,11 @@ func getLogFile(source OutputSource, logConfig string, conf *config.Config, task
 		return ""
 	}
 
+	logConfig, logFileName := taskConf.Stdout, taskConf.StdoutLogFile
+	if source == STDERR {
+		logConfig, logFileName = taskConf.Stderr, taskConf.StderrLogFile
+	}
+
 	if logConfig == "inherit" {
 		switch source {
 		case STDOUT:
@@ -198,10 +337,8 @@ func getLogFile(source OutputSource, logConfig string, conf *config.Config, task
 		return nil, errors.New("source must be either STDOUT or STDERR")
 	}
 
-	var logFileName string
-
-	switch logConfig {
-	case "redirect":
+	switch {
+	case logConfig == "redirect" && len(logFileName) == 0:
 		logFileName = fmt.Sprintf(
 			"%s/%d-%d_%s-%s.log",
 			conf.LogDir,
@@ -210,7 +347,10 @@ func getLogFile(source OutputSource, logConfig string, conf *config.Config, task
 			time.Now().Format("060102_150405"),
 			getSourceName(),
 		)
-	case "ignore":
+	case logConfig == "redirect" && !filepath.IsAbs(logFileName):
+		logFileName = filepath.Join(conf.LogDir, logFileName)
+	case logConfig == "redirect":
+	case logConfig == "ignore":
 		logFileName = "/dev/null"
 	default:
 		return nil, fmt.Errorf("Invalid log configuration %s", logConfig)
@@ -223,25 +363,36 @@ func getLogFile(source OutputSource, logConfig string, conf *config.Config, task
 	)
 }
 
-func newProcessRunner(manager config.Manager, taskId, id uint, input <-chan input.Message, output chan<- output.Message) (*ProcessRunner, error) {
-	conf := manager.Get()
-	taskConf := conf.Tasks[taskId]
+// Close a log file of a process, unless it is a stream inherited from
+// taskmaster
+func closeLogFile(file *os.File) {
+	if file != os.Stdout && file != os.Stderr {
+		file.Close()
+	}
+}
+
+func newProcessRunner(conf *config.Config, taskId, id uint, input <-chan input.Message, output chan<- output.Message) (*ProcessRunner, error) {
+	taskConf, err := conf.Tasks[taskId].Render(config.NewTemplateData(conf, &conf.Tasks[taskId], id))
+	if err != nil {
+		return nil, err
+	}
 	instance := &ProcessRunner{
-		ConfigManager:  manager,
-		TaskConfig:     taskConf,
-		TaskId:         taskId,
+		TaskConfig:     atom.NewAtom(taskConf),
+		TaskId:         atom.NewAtom(taskId),
 		Id:             id,
 		Input:          input,
 		Output:         output,
 		State:          NewProcessState(),
-		internalOutput: make(chan ProcessResponse),
+		internalOutput: make(chan processNotification),
 		commandErrors:  make(chan error),
 		stopSignal:     make(chan StopSignal),
 		startInterrupt: make(chan startInterrupt),
+		outputCopies:   new(sync.WaitGroup),
 	}
-	if stdoutLogFile, err := getLogFile(STDOUT, taskConf.Stdout, conf, taskId, id); err != nil {
+	if stdoutLogFile, err := getLogFile(STDOUT, &taskConf, conf, taskId, id); err != nil {
 		return nil, err
-	} else if stderrLogFile, err := getLogFile(STDERR, taskConf.Stderr, conf, taskId, id); err != nil {
+	} else if stderrLogFile, err := getLogFile(STDERR, &taskConf, conf, taskId, id); err != nil {
+		closeLogFile(stdoutLogFile)
 		return nil, err
 	} else {
 		instance.StdoutLogFile = stdoutLogFile
@@ -251,15 +402,23 @@ func newProcessRunner(manager config.Manager, taskId, id uint, input <-chan inpu
 }
 
 func (this *ProcessRunner) StartProcess() error {
-	this.initCommand()
+	pipes, err := this.initCommand()
+	if err != nil {
+		this.State.failedToStart.Set(true)
+		return err
+	}
 
 	this.State.startTime.Set(utils.New(time.Now()))
 	this.State.exitStatus.Set(nil)
 	command := this.State.command.Get()
 
-	oldUmask := syscall.Umask(int(*this.TaskConfig.Permissions))
-	err := command.Start()
+	oldUmask := syscall.Umask(int(*this.TaskConfig.Get().Permissions))
+	err = command.Start()
 	syscall.Umask(oldUmask)
+	// The process has its own copy of the pipes once started
+	for _, pipe := range pipes {
+		pipe.Close()
+	}
 
 	if err != nil {
 		this.State.failedToStart.Set(true)
@@ -274,8 +433,11 @@ func (this *ProcessRunner) StartProcess() error {
 				default:
 				}
 				this.State.Reset()
-				this.internalOutput <- RETRYING
-				this.StartProcess()
+				this.notify(RETRYING)
+				if err := this.StartProcess(); err != nil {
+					this.notify(STARTING_ERROR)
+					this.commandErrors <- err
+				}
 			}
 
 			state, _ := command.Process.Wait()
@@ -284,26 +446,33 @@ func (this *ProcessRunner) StartProcess() error {
 				exitCode,
 			))
 			this.State.stopTime.Set(utils.New(time.Now()))
-			hasAttempts := this.TaskConfig.RestartAttempts == 0 ||
-				this.State.startRetries.Get() < this.TaskConfig.RestartAttempts
-			if exitCode == this.TaskConfig.ExpectedExitStatus {
-				this.internalOutput <- STOPPED_SUCCESSFULLY
+			hasAttempts := this.TaskConfig.Get().RestartAttempts == 0 ||
+				this.State.startRetries.Get() < this.TaskConfig.Get().RestartAttempts
+			if exitCode == this.TaskConfig.Get().ExpectedExitStatus {
+				this.notify(STOPPED_SUCCESSFULLY)
 			} else {
-				this.internalOutput <- STOPPED_UNSUCCESSFULLY
+				this.notify(STOPPED_UNSUCCESSFULLY)
 			}
 			if !this.State.isRestarting.Get() && !this.State.hasBeenShutdown.Get() {
-				if this.TaskConfig.StartTime != 0 && this.State.userStartTime.Get() == nil {
+				if this.TaskConfig.Get().StartTime != 0 && this.State.userStartTime.Get() == nil {
 					this.State.stoppedEarly.Set(true)
-					if hasAttempts && this.TaskConfig.Restart != "never" {
+					if hasAttempts && this.TaskConfig.Get().Restart != "never" {
 						retry()
 					} else {
+						if this.TaskConfig.Get().Restart != "never" {
+							this.notify(RETRIES_EXHAUSTED)
+						}
 						this.startInterrupt <- startInterrupt{}
 					}
-				} else if hasAttempts && (this.TaskConfig.Restart == "always" ||
-					(this.TaskConfig.Restart == "unless-stopped" &&
+				} else if this.TaskConfig.Get().Restart == "always" ||
+					(this.TaskConfig.Get().Restart == "unless-stopped" &&
 						this.State.userStopTime.Get() == nil &&
-						exitCode != this.TaskConfig.ExpectedExitStatus)) {
-					retry()
+						exitCode != this.TaskConfig.Get().ExpectedExitStatus) {
+					if hasAttempts {
+						retry()
+					} else {
+						this.notify(RETRIES_EXHAUSTED)
+					}
 				}
 			} else if this.State.userStartTime.Get() == nil {
 				select {
@@ -318,11 +487,11 @@ func (this *ProcessRunner) StartProcess() error {
 		go func() {
 			select {
 			case <-this.startInterrupt:
-			case <-time.After(time.Duration(this.TaskConfig.StartTime) * time.Millisecond):
+			case <-time.After(this.TaskConfig.Get().StartTime.Duration()):
 				if this.State.userStartTime.Get() == nil {
 					this.State.stoppedEarly.Set(false)
 					this.State.userStartTime.Set(utils.New(time.Now()))
-					this.internalOutput <- STARTED
+					this.notify(STARTED)
 				}
 			}
 		}()
@@ -337,9 +506,9 @@ func (this *ProcessRunner) StopProcess() {
 	}
 	if process := this.State.command.Get().Process; process != nil {
 		this.State.userStopTime.Set(utils.New(time.Now()))
-		process.Signal(SIGNAL_TABLE[this.TaskConfig.StopSignal])
+		process.Signal(SIGNAL_TABLE[this.TaskConfig.Get().StopSignal])
 		go func() {
-			time.Sleep(time.Duration(this.TaskConfig.StopTime) * time.Millisecond)
+			time.Sleep(this.TaskConfig.Get().StopTime.Duration())
 			if this.State.exitStatus.Get() == nil {
 				this.State.hasBeenKilled.Set(true)
 				process.Kill()
@@ -354,7 +523,7 @@ func (this *ProcessRunner) RestartProcess() {
 		<-this.stopSignal
 		this.State.Reset()
 		if err := this.StartProcess(); err != nil {
-			this.internalOutput <- RESTARTING_ERROR
+			this.notify(RESTARTING_ERROR)
 			this.commandErrors <- err
 		}
 	}()
@@ -368,7 +537,7 @@ func (this *ProcessRunner) StatusProcess() {
 	case this.State.failedToStart.Get():
 		status += "FAILED_TO_START"
 	case stopTime != nil && exitStatus != nil:
-		expectedExitStatus := this.TaskConfig.ExpectedExitStatus
+		expectedExitStatus := this.TaskConfig.Get().ExpectedExitStatus
 		status += ""
 		if *exitStatus == expectedExitStatus {
 			status += "SUCCESS "
@@ -389,8 +558,8 @@ func (this *ProcessRunner) StatusProcess() {
 		status += "RUNNING"
 	}
 	retries := this.State.startRetries.Get()
-	if this.TaskConfig.RestartAttempts != 0 && retries != 0 {
-		status += fmt.Sprintf(" [Retried %d/%d]", retries, this.TaskConfig.RestartAttempts)
+	if this.TaskConfig.Get().RestartAttempts != 0 && retries != 0 {
+		status += fmt.Sprintf(" [Retried %d/%d]", retries, this.TaskConfig.Get().RestartAttempts)
 	}
 	if this.State.stoppedEarly.Get() {
 		status += " (stopped early)"
@@ -401,21 +570,30 @@ func (this *ProcessRunner) StatusProcess() {
 func (this *ProcessRunner) Run() {
 	defer this.close()
 	go func() {
-		actionError := func(action string) string {
-			err := <-this.commandErrors
-			return fmt.Sprintf("failed to %s: %s", action, err.Error())
-		}
-		for req := range this.internalOutput {
-			msg := fmt.Sprintf("[%d - %d] ", this.TaskId, this.Id)
-			switch req {
+		for notification := range this.internalOutput {
+			event := events.Event{
+				Type:      notification.response.String(),
+				Task:      *this.TaskConfig.Get().Name,
+				TaskId:    this.TaskId.Get(),
+				Instance:  this.Id,
+				Pid:       notification.pid,
+				StartedAt: notification.startTime,
+				ExitCode:  notification.exitCode,
+				Requested: notification.requested,
+				Retries:   notification.retries,
+			}
+			msg := fmt.Sprintf("[%d - %d] ", this.TaskId.Get(), this.Id)
+			switch notification.response {
 			case STARTING:
 				msg += "starting"
 			case RESTARTING:
 				msg += "restarting"
 			case STARTING_ERROR:
-				msg += actionError("start")
+				event.Error = (<-this.commandErrors).Error()
+				msg += fmt.Sprintf("failed to start: %s", event.Error)
 			case RESTARTING_ERROR:
-				msg += actionError("restart")
+				event.Error = (<-this.commandErrors).Error()
+				msg += fmt.Sprintf("failed to restart: %s", event.Error)
 			case RETRYING:
 				msg += "retrying"
 			case STARTED:
@@ -426,16 +604,17 @@ func (this *ProcessRunner) Run() {
 				msg += "stopped successfully"
 			case STOPPED_UNSUCCESSFULLY:
 				msg += "stopped unsuccessfully"
+			case RETRIES_EXHAUSTED:
+				msg += "no restart attempts left"
 			}
-			fmt.Printf("\033[2K\r%s\n", msg)
-			shell.DisplayCommand()
-			fmt.Fprintf(TaskmasterLogFile.Get(), "%s: %s\n", time.Now().Format("06/01/02 15:04:05"), msg)
+			Report(msg)
+			Events.Publish(event)
 		}
 	}()
-	if this.TaskConfig.StartAtLaunch {
-		this.internalOutput <- STARTING
+	if this.TaskConfig.Get().StartAtLaunch {
+		this.notify(STARTING)
 		if err := this.StartProcess(); err != nil {
-			this.internalOutput <- STARTING_ERROR
+			this.notify(STARTING_ERROR)
 			this.commandErrors <- err
 		}
 	}
@@ -457,14 +636,16 @@ func (this *ProcessRunner) Run() {
 				err = utils.New(ERROR_START_STOPPED)
 			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\033[2K\rCannot start process: %s\n", *err)
-				shell.DisplayCommand()
+				this.Output <- output.NewStartFailure(*err)
 				break
 			}
-			this.internalOutput <- STARTING
+			this.notify(STARTING)
 			if err := this.StartProcess(); err != nil {
-				this.internalOutput <- STARTING_ERROR
+				this.notify(STARTING_ERROR)
 				this.commandErrors <- err
+				this.Output <- output.NewStartFailure(err.Error())
+			} else {
+				this.Output <- output.NewStartSuccess()
 			}
 
 		case input.Stop:
@@ -475,22 +656,27 @@ func (this *ProcessRunner) Run() {
 				err = utils.New(ERROR_STOP_STOPPED)
 			}
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\033[2K\rCannot stop process: %s\n", *err)
-				shell.DisplayCommand()
+				this.Output <- output.NewStopFailure(*err)
 				break
 			}
-			this.internalOutput <- STOPPING
+			this.notify(STOPPING)
 			this.StopProcess()
+			this.Output <- output.NewStopSuccess()
 
 		case input.Restart:
 			if this.State.failedToStart.Get() {
-				fmt.Fprintf(os.Stderr, "\033[2K\rCannot restart process: %s\n", ERROR_PREVIOUSLY_FAILED)
-				shell.DisplayCommand()
+				this.Output <- output.NewRestartFailure(ERROR_PREVIOUSLY_FAILED)
 				break
 			}
-			this.internalOutput <- RESTARTING
+			this.notify(RESTARTING)
 			this.State.isRestarting.Set(true)
 			this.RestartProcess()
+			this.Output <- output.NewRestartSuccess()
+
+		case input.Update:
+			req := req.(input.Update)
+			this.TaskConfig.Set(req.Config())
+			this.TaskId.Set(req.TaskId())
 
 		case input.Shutdown:
 			return
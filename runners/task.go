@@ -10,8 +10,6 @@ import (
 	processOutput "taskmaster/messages/process/output"
 	"taskmaster/messages/task/input"
 	"taskmaster/messages/task/output"
-	"taskmaster/shell"
-	"taskmaster/utils"
 )
 
 type TaskRunner struct {
@@ -22,13 +20,42 @@ type TaskRunner struct {
 	Input  <-chan input.Message
 	Output chan<- output.Message
 
-	Processes             []*ProcessRunner
-	GlobalProcessesOutput chan []processOutput.Message
+	// Only changed by the loop of Run once it started, when the number of
+	// instances is updated
+	Processes []*ProcessRunner
 
-	processInputs  []chan processInput.Message
-	processOutputs []chan processOutput.Message
+	processInputs []chan processInput.Message
+	// Requests of the master waiting for the answer of each process
+	pendingRequests []*pendingRequests
+	// Number of processes whose runner was started, the next ones were
+	// added by an update that is not committed yet
+	started uint
 
-	globalOutputPipes []chan processOutput.Message
+	globalOutputPipes     []chan processOutput.Message
+	outputLinks           *sync.WaitGroup
+	processesClosed       *sync.WaitGroup
+	specificProcessClosed []*sync.WaitGroup
+}
+
+// Ids of the task in the requests sent to a process, in the order the
+// process answers them
+type pendingRequests struct {
+	lock    *sync.Mutex
+	taskIds []uint
+}
+
+func (this *pendingRequests) push(taskId uint) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.taskIds = append(this.taskIds, taskId)
+}
+
+func (this *pendingRequests) pop() uint {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	taskId := this.taskIds[0]
+	this.taskIds = this.taskIds[1:]
+	return taskId
 }
 
 type buildConfig struct {
@@ -36,76 +63,167 @@ type buildConfig struct {
 	instances uint
 }
 
-func newTaskRunner(manager config.Manager, id uint, input <-chan input.Message, output chan<- output.Message) (*TaskRunner, error) {
-	conf := manager.Get().Tasks[id]
-	processInputs := make([]chan processInput.Message, conf.Instances)
-	processOutputs := make([]chan processOutput.Message, conf.Instances)
-
-	globalProcessesOutput := make(chan []processOutput.Message)
+func newTaskRunner(conf *config.Config, id uint, in <-chan input.Message, out chan<- output.Message) (*TaskRunner, error) {
+	taskConf := conf.Tasks[id]
 
 	instance := &TaskRunner{
-		Config:                conf,
+		Config:                taskConf,
 		Id:                    id,
-		Input:                 input,
-		Output:                output,
-		Processes:             make([]*ProcessRunner, conf.Instances),
-		GlobalProcessesOutput: globalProcessesOutput,
-		processInputs:         processInputs,
-		processOutputs:        processOutputs,
-		globalOutputPipes:     make([]chan processOutput.Message, len(processOutputs)),
+		Input:                 in,
+		Output:                out,
+		Processes:             []*ProcessRunner{},
+		processInputs:         []chan processInput.Message{},
+		pendingRequests:       []*pendingRequests{},
+		globalOutputPipes:     []chan processOutput.Message{},
+		outputLinks:           new(sync.WaitGroup),
+		processesClosed:       new(sync.WaitGroup),
+		specificProcessClosed: []*sync.WaitGroup{},
 	}
 
-	for i := range instance.Processes {
-		processInputs[i] = make(chan processInput.Message)
-		processOutputs[i] = make(chan processOutput.Message)
-		if process, err := newProcessRunner(manager, id, uint(i), processInputs[i], processOutputs[i]); err != nil {
+	for i := range taskConf.Instances {
+		if err := instance.addProcess(conf, id, i); err != nil {
+			instance.discard()
 			return nil, err
-		} else {
-			instance.Processes[i] = process
 		}
 	}
+	return instance, nil
+}
 
-	for i, out := range processOutputs {
-		instance.globalOutputPipes[i] = make(chan processOutput.Message)
-		i := i
-		out := out
-		go func() {
-			for msg := range out {
-				switch msg.(type) {
-				case helpers.Global:
-					instance.globalOutputPipes[i] <- msg
-				}
-			}
-		}()
+// Create the runner of the instance `id` of the task at `taskId` in `conf`,
+// and forward its output to the output of the task
+func (this *TaskRunner) addProcess(conf *config.Config, taskId, id uint) error {
+	in := make(chan processInput.Message)
+	out := make(chan processOutput.Message)
+	process, err := newProcessRunner(conf, taskId, id, in, out)
+	if err != nil {
+		return err
 	}
 
+	globalOutputPipe := make(chan processOutput.Message)
+	pending := &pendingRequests{lock: new(sync.Mutex), taskIds: []uint{}}
+	this.Processes = append(this.Processes, process)
+	this.processInputs = append(this.processInputs, in)
+	this.pendingRequests = append(this.pendingRequests, pending)
+	this.globalOutputPipes = append(this.globalOutputPipes, globalOutputPipe)
+	this.specificProcessClosed = append(this.specificProcessClosed, new(sync.WaitGroup))
+
+	this.outputLinks.Add(1)
 	go func() {
-		chunk := make([]processOutput.Message, len(instance.globalOutputPipes))
-		for {
-			for i, ch := range instance.globalOutputPipes {
-				if value, ok := <-ch; !ok {
-					return
-				} else {
-					chunk[i] = value
-				}
+		defer this.outputLinks.Done()
+		defer close(globalOutputPipe)
+		for msg := range out {
+			switch msg.(type) {
+			case helpers.Global:
+				globalOutputPipe <- msg
+			case processOutput.Start:
+				this.Output <- output.NewStartProcess(pending.pop(), id, msg.(processOutput.Start))
+			case processOutput.Stop:
+				this.Output <- output.NewStopProcess(pending.pop(), id, msg.(processOutput.Stop))
+			case processOutput.Restart:
+				this.Output <- output.NewRestartProcess(pending.pop(), id, msg.(processOutput.Restart))
 			}
-			globalProcessesOutput <- chunk
 		}
 	}()
+	return nil
+}
+
+func (this *TaskRunner) runProcess(id uint) {
+	process, closed := this.Processes[id], this.specificProcessClosed[id]
+	this.processesClosed.Add(1)
+	closed.Add(1)
+	go func() {
+		process.Run()
+		closed.Done()
+		this.processesClosed.Done()
+	}()
+}
 
-	return instance, nil
+// Forget the last instance of the task
+func (this *TaskRunner) popProcess() *ProcessRunner {
+	last := len(this.Processes) - 1
+	process := this.Processes[last]
+	this.Processes = this.Processes[:last]
+	this.processInputs = this.processInputs[:last]
+	this.pendingRequests = this.pendingRequests[:last]
+	this.globalOutputPipes = this.globalOutputPipes[:last]
+	this.specificProcessClosed = this.specificProcessClosed[:last]
+	return process
 }
 
-func (this *TaskRunner) close(processClosed *sync.WaitGroup) {
-	for _, ch := range this.processInputs {
-		close(ch)
+// Stop the last instance of the task and forget it
+func (this *TaskRunner) removeProcess() {
+	last := len(this.Processes) - 1
+	this.processInputs[last] <- processInput.NewShutdown()
+	this.specificProcessClosed[last].Wait()
+	close(this.processInputs[last])
+	this.popProcess()
+}
+
+// Apply a configuration whose changes to the task can be applied without
+// restarting it, the task being at `id` in it: live properties are sent to
+// the running processes, and the instances to start are created. Nothing is
+// started or stopped before commitUpdate, for a later update to undo it. The
+// processes are left as they were if it fails.
+func (this *TaskRunner) update(conf *config.Config, id uint) error {
+	taskConf := conf.Tasks[id]
+	for uint(len(this.Processes)) > this.started {
+		this.popProcess().discard()
+	}
+	count := this.started
+
+	rendered := []config.Task{}
+	for i := range min(count, taskConf.Instances) {
+		if process, err := taskConf.Render(config.NewTemplateData(conf, &taskConf, i)); err != nil {
+			return err
+		} else {
+			rendered = append(rendered, process)
+		}
 	}
-	processClosed.Wait()
+	for i := count; i < taskConf.Instances; i++ {
+		if err := this.addProcess(conf, id, i); err != nil {
+			for uint(len(this.Processes)) > count {
+				this.popProcess().discard()
+			}
+			return err
+		}
+	}
+
+	this.Config = taskConf
+	this.Id = id
+	for i, process := range rendered {
+		this.processInputs[i] <- processInput.NewUpdate(process, id)
+	}
+	return nil
+}
+
+// Stop the last instances left over by update and start the ones it added,
+// for the task to run `instances` processes
+func (this *TaskRunner) commitUpdate() {
+	for uint(len(this.Processes)) > this.Config.Instances {
+		this.removeProcess()
+		this.started--
+	}
+	for ; this.started < uint(len(this.Processes)); this.started++ {
+		this.runProcess(this.started)
+	}
+}
+
+// Release what a task that never ran holds
+func (this *TaskRunner) discard() {
+	for len(this.Processes) != 0 {
+		this.popProcess().discard()
+	}
+	this.outputLinks.Wait()
 	close(this.Output)
-	for _, ch := range this.globalOutputPipes {
+}
+
+func (this *TaskRunner) close() {
+	for _, ch := range this.processInputs {
 		close(ch)
 	}
-	close(this.GlobalProcessesOutput)
+	this.processesClosed.Wait()
+	this.outputLinks.Wait()
+	close(this.Output)
 }
 
 func (this *TaskRunner) forwardGlobalMessage(message interface {
@@ -118,70 +236,58 @@ func (this *TaskRunner) forwardGlobalMessage(message interface {
 }
 
 func (this *TaskRunner) Run() {
-	var processClosed sync.WaitGroup
-	defer this.close(&processClosed)
-
-	for _, proc := range this.Processes {
-		processClosed.Add(1)
-		go func() {
-			proc.Run()
-			processClosed.Done()
-		}()
-	}
+	defer this.close()
 
-	go func() {
-
-		for global := range this.GlobalProcessesOutput {
-			switch global[0].(type) {
-
-			case processOutput.Status:
-				this.Output <- output.NewStatus(
-					this.Id,
-					*this.Config.Name,
-					utils.Transform(
-						global,
-						func(i int, elem *processOutput.Message) processOutput.Status {
-							return (*elem).(processOutput.Status)
-						},
-					),
-				)
-			}
-		}
-	}()
+	for ; this.started < uint(len(this.Processes)); this.started++ {
+		this.runProcess(this.started)
+	}
 
 	for req := range this.Input {
 		switch req.(type) {
 
 		case input.Status:
 			this.forwardGlobalMessage(processInput.NewStatus())
+			statuses := make([]processOutput.Status, len(this.globalOutputPipes))
+			for i, pipe := range this.globalOutputPipes {
+				statuses[i] = (<-pipe).(processOutput.Status)
+			}
+			this.Output <- output.NewStatus(this.Id, *this.Config.Name, statuses)
 
 		case input.StartProcess:
 			req := req.(input.StartProcess)
 			if req.ProcessId() >= uint(len(this.Processes)) {
-				fmt.Printf("\033[2K\rTask %d failed to start process: invalid process id: %d.\n", this.Id, req.ProcessId())
-				shell.DisplayCommand()
+				this.Output <- output.NewStartProcessFailure(req.TaskId(), req.ProcessId(), fmt.Sprintf("invalid process id: %d", req.ProcessId()))
 				break
 			}
+			this.pendingRequests[req.ProcessId()].push(req.TaskId())
 			this.processInputs[req.ProcessId()] <- processInput.NewStart()
 
 		case input.StopProcess:
 			req := req.(input.StopProcess)
 			if req.ProcessId() >= uint(len(this.Processes)) {
-				fmt.Printf("\033[2K\rTask %d failed to stop process: invalid process id: %d.\n", this.Id, req.ProcessId())
-				shell.DisplayCommand()
+				this.Output <- output.NewStopProcessFailure(req.TaskId(), req.ProcessId(), fmt.Sprintf("invalid process id: %d", req.ProcessId()))
 				break
 			}
+			this.pendingRequests[req.ProcessId()].push(req.TaskId())
 			this.processInputs[req.ProcessId()] <- processInput.NewStop()
 
 		case input.RestartProcess:
 			req := req.(input.RestartProcess)
 			if req.ProcessId() >= uint(len(this.Processes)) {
-				fmt.Printf("\033[2K\rTask %d failed to restart process: invalid process id: %d.\n", this.Id, req.ProcessId())
-				shell.DisplayCommand()
+				this.Output <- output.NewRestartProcessFailure(req.TaskId(), req.ProcessId(), fmt.Sprintf("invalid process id: %d", req.ProcessId()))
 				break
 			}
+			this.pendingRequests[req.ProcessId()].push(req.TaskId())
 			this.processInputs[req.ProcessId()] <- processInput.NewRestart()
 
+		case input.Update:
+			req := req.(input.Update)
+			req.Result() <- this.update(req.Config(), req.TaskId())
+
+		case input.CommitUpdate:
+			this.commitUpdate()
+			req.(input.CommitUpdate).Done() <- struct{}{}
+
 		case input.Shutdown:
 			this.forwardGlobalMessage(processInput.NewShutdown())
 			return
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"taskmaster/config"
+)
+
+//go:generate sh -c "go run . schema > tmconfig.schema.json"
+
+// Print the JSON schema of the configuration files, generated from the Go
+// definition of the configuration
+func schemaCommand(args []string) int {
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s schema\n", os.Args[0])
+	}
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	schema, err := config.JSONSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(schema)
+	return 0
+}
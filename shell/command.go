@@ -3,6 +3,7 @@ package shell
 import (
 	"fmt"
 	"sync"
+	"taskmaster/atom"
 )
 
 var commandLock sync.Mutex
@@ -10,7 +11,13 @@ var commandLock sync.Mutex
 var command string
 var cursor int
 
+// Whether an interactive shell currently owns the terminal
+var active = atom.NewAtom(false)
+
 func DisplayCommand() {
+	if !active.Get() {
+		return
+	}
 	commandLock.Lock()
 	fmt.Printf("\033[2K\r> %s", command)
 	if len(command) != cursor {
@@ -18,3 +25,14 @@ func DisplayCommand() {
 	}
 	commandLock.Unlock()
 }
+
+// Print a message on its own line without messing with the command
+// being typed. Outside of an interactive shell, the message is printed as is.
+func Notify(message string) {
+	if !active.Get() {
+		fmt.Println(message)
+		return
+	}
+	fmt.Printf("\033[2K\r%s\n", message)
+	DisplayCommand()
+}
@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+
+	"taskmaster/messages/helpers"
+	"taskmaster/messages/master/output"
+)
+
+// Describe a response from the master in a human readable way.
+// `failed` is true if the response reports an error.
+// Successful process actions are already reported by the process events,
+// so their description is empty.
+func FormatResponse(res output.Message) (message string, failed bool) {
+	switch res.(type) {
+	case output.Status:
+		lines := []string{}
+		for _, task := range res.(output.Status).Tasks() {
+			lines = append(lines, fmt.Sprintf("%d -- %s", task.TaskId(), task.Name()))
+			for _, proc := range task.Processes() {
+				lines = append(lines, fmt.Sprintf("  %d -- %s", proc.ProcessId(), proc.Value()))
+			}
+		}
+		return strings.Join(lines, "\n"), false
+
+	case output.Reload:
+		switch res.(type) {
+		case helpers.Success:
+			return "Successfully reloaded configuration.", false
+		case helpers.Failure:
+			return fmt.Sprintf("Failed to reload configuration: %s.", res.(output.ReloadFailure).Reason()), true
+		}
+
+	case output.PlanReload:
+		switch res := res.(type) {
+		case output.PlanReloadSuccess:
+			lines := []string{}
+			for _, change := range res.Tasks() {
+				lines = append(lines, change.String())
+			}
+			return strings.Join(lines, "\n"), false
+		case output.PlanReloadFailure:
+			return fmt.Sprintf("Reloading the configuration would fail: %s.", res.Reason()), true
+		}
+
+	case output.StartProcess:
+		if res, ok := res.(output.StartProcessFailure); ok {
+			return fmt.Sprintf("Task %d failed to start process %d: %s.", res.TaskId(), res.ProcessId(), res.Reason()), true
+		}
+
+	case output.StopProcess:
+		if res, ok := res.(output.StopProcessFailure); ok {
+			return fmt.Sprintf("Task %d failed to stop process %d: %s.", res.TaskId(), res.ProcessId(), res.Reason()), true
+		}
+
+	case output.RestartProcess:
+		if res, ok := res.(output.RestartProcessFailure); ok {
+			return fmt.Sprintf("Task %d failed to restart process %d: %s.", res.TaskId(), res.ProcessId(), res.Reason()), true
+		}
+
+	case output.Shutdown:
+		return "Taskmaster has been shut down.", false
+
+	case output.BadRequest:
+		return "Invalid request.", true
+
+	case output.Forbidden:
+		return "Permission denied.", true
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package shell
+
+import (
+	"testing"
+
+	"taskmaster/config"
+	"taskmaster/messages/master/output"
+	processOutput "taskmaster/messages/process/output"
+	taskOutput "taskmaster/messages/task/output"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatResponse(t *testing.T) {
+	tests := []struct {
+		response output.Message
+		message  string
+		failed   bool
+	}{
+		{
+			output.NewStatus([]taskOutput.Status{
+				taskOutput.NewStatus(0, "web", []processOutput.Status{
+					processOutput.NewStatus(0, "RUNNING"),
+					processOutput.NewStatus(1, "STOPPED"),
+				}),
+				taskOutput.NewStatus(1, "worker", []processOutput.Status{}),
+			}),
+			"0 -- web\n  0 -- RUNNING\n  1 -- STOPPED\n1 -- worker",
+			false,
+		},
+		{output.NewStatus([]taskOutput.Status{}), "", false},
+		{output.NewReloadSuccess(), "Successfully reloaded configuration.", false},
+		{output.NewReloadFailure("No task to run"), "Failed to reload configuration: No task to run.", true},
+		{
+			output.NewPlanReloadSuccess([]config.TaskChange{
+				{Name: "web", Action: config.TASK_SCALED, Properties: []string{"instances"}, Instances: 1, NextInstances: 3},
+				{Name: "worker", Action: config.TASK_REMOVED, Instances: 2},
+			}),
+			"web: scaled from 1 to 3 instances, instances changed\nworker: removed, stopping 2 instances",
+			false,
+		},
+		{output.NewPlanReloadFailure("No task to run"), "Reloading the configuration would fail: No task to run.", true},
+		{output.NewStartProcessSuccess(0, "web", 1), "", false},
+		{output.NewStartProcessFailure(0, "web", 1, "process is already running"), "Task 0 failed to start process 1: process is already running.", true},
+		{output.NewStopProcessSuccess(2, "web", 0), "", false},
+		{output.NewStopProcessFailure(2, "web", 0, "process is not running"), "Task 2 failed to stop process 0: process is not running.", true},
+		{output.NewRestartProcessSuccess(1, "web", 1), "", false},
+		{output.NewRestartProcessFailure(1, "web", 1, "invalid process id: 1"), "Task 1 failed to restart process 1: invalid process id: 1.", true},
+		{output.NewShutdown(), "Taskmaster has been shut down.", false},
+		{output.NewBadRequest(), "Invalid request.", true},
+		{output.NewForbidden(), "Permission denied.", true},
+	}
+	for _, test := range tests {
+		message, failed := FormatResponse(test.response)
+		require.Equal(t, test.message, message)
+		require.Equal(t, test.failed, failed, test.message)
+	}
+}
@@ -0,0 +1,81 @@
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"taskmaster/messages/master/input"
+)
+
+type CommandError struct {
+	cause string
+}
+
+func (this CommandError) Error() string {
+	return this.cause
+}
+
+func newCommandError(cause string) CommandError {
+	return CommandError{cause}
+}
+
+func parseProcessIds(cmd []string) (taskId, processId uint, err error) {
+	if len(cmd) != 3 {
+		return 0, 0, newCommandError(fmt.Sprintf("usage: %s <task-id> <process-id>", cmd[0]))
+	}
+	task, taskErr := strconv.ParseUint(cmd[1], 10, 64)
+	process, processErr := strconv.ParseUint(cmd[2], 10, 64)
+	if taskErr != nil || processErr != nil {
+		return 0, 0, newCommandError("Error: task-id and process-id must be valid positive integers")
+	}
+	return uint(task), uint(process), nil
+}
+
+// Build the request matching a command split into tokens.
+// The `help` command is not a request, and has to be handled by the caller.
+func ParseCommand(cmd []string) (input.Message, error) {
+	if len(cmd) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	switch cmd[0] {
+	case "status":
+		return input.NewStatus(), nil
+
+	case "start":
+		if taskId, processId, err := parseProcessIds(cmd); err != nil {
+			return nil, err
+		} else {
+			return input.NewStartProcess(taskId, processId), nil
+		}
+
+	case "stop":
+		if taskId, processId, err := parseProcessIds(cmd); err != nil {
+			return nil, err
+		} else {
+			return input.NewStopProcess(taskId, processId), nil
+		}
+
+	case "restart":
+		if taskId, processId, err := parseProcessIds(cmd); err != nil {
+			return nil, err
+		} else {
+			return input.NewRestartProcess(taskId, processId), nil
+		}
+
+	case "reload":
+		switch {
+		case len(cmd) == 1:
+			return input.NewReload(), nil
+		case len(cmd) == 2 && cmd[1] == "--dry-run":
+			return input.NewPlanReload(), nil
+		}
+		return nil, newCommandError("usage: reload [--dry-run]")
+
+	case "shutdown":
+		return input.NewShutdown(), nil
+	}
+
+	return nil, newCommandError(fmt.Sprintf("invalid command: %s (type `help` to get a list of available commands)", cmd[0]))
+}
@@ -0,0 +1,52 @@
+package shell
+
+import (
+	"testing"
+
+	"taskmaster/messages/master/input"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		command  []string
+		expected input.Message
+	}{
+		{[]string{"status"}, input.NewStatus()},
+		{[]string{"start", "0", "1"}, input.NewStartProcess(0, 1)},
+		{[]string{"stop", "2", "0"}, input.NewStopProcess(2, 0)},
+		{[]string{"restart", "1", "3"}, input.NewRestartProcess(1, 3)},
+		{[]string{"reload"}, input.NewReload()},
+		{[]string{"reload", "--dry-run"}, input.NewPlanReload()},
+		{[]string{"shutdown"}, input.NewShutdown()},
+	}
+	for _, test := range tests {
+		req, err := ParseCommand(test.command)
+		require.Nil(t, err, test.command)
+		require.Equal(t, test.expected, req, test.command)
+	}
+}
+
+func TestParseCommandErrors(t *testing.T) {
+	tests := []struct {
+		command []string
+		err     string
+	}{
+		{[]string{}, "empty command"},
+		{[]string{"start"}, "usage: start <task-id> <process-id>"},
+		{[]string{"stop", "0"}, "usage: stop <task-id> <process-id>"},
+		{[]string{"restart", "0", "1", "2"}, "usage: restart <task-id> <process-id>"},
+		{[]string{"start", "web", "0"}, "Error: task-id and process-id must be valid positive integers"},
+		{[]string{"stop", "0", "-1"}, "Error: task-id and process-id must be valid positive integers"},
+		{[]string{"reload", "now"}, "usage: reload [--dry-run]"},
+		{[]string{"reload", "--dry-run", "now"}, "usage: reload [--dry-run]"},
+		{[]string{"kill"}, "invalid command: kill (type `help` to get a list of available commands)"},
+	}
+	for _, test := range tests {
+		req, err := ParseCommand(test.command)
+		require.Nil(t, req, test.command)
+		require.NotNil(t, err, test.command)
+		require.Equal(t, test.err, err.Error(), test.command)
+	}
+}
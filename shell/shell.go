@@ -6,11 +6,9 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"taskmaster/atom"
-	"taskmaster/messages/helpers"
 	"taskmaster/messages/master/input"
 	"taskmaster/messages/master/output"
 	"taskmaster/terminal"
@@ -24,6 +22,7 @@ start <id>: start a program
 stop <id>: stop a program
 restart <id>: restart a program
 reload: reload configuration file (restart programs only if needed)
+reload --dry-run: show what reloading the configuration file would change
 shutdown: stop all processes and taskmaster`
 
 type SpecialKey uint
@@ -46,8 +45,10 @@ func StartShell(in <-chan output.Message, out chan<- input.Message) {
 
 	terminal.DisableEchoMode()
 	terminal.DisableCannonicalMode()
+	active.Set(true)
 	defer func() {
 		shouldStop.Set(true)
+		active.Set(false)
 		close(out)
 		terminal.EnableEchoMode()
 		terminal.EnableCannonicalMode()
@@ -155,60 +156,15 @@ func StartShell(in <-chan output.Message, out chan<- input.Message) {
 		select {
 
 		case cmd := <-commands:
-			switch cmd[0] {
-			case "help":
+			if cmd[0] == "help" {
 				fmt.Println(HELP_MESSAGE)
-
-			case "status":
-				out <- input.NewStatus()
-
-			case "start":
-				if len(cmd) != 3 {
-					fmt.Fprintln(os.Stderr, "usage: start <task-id> <process-id>")
-					break
-				}
-				taskId, taskIdErr := strconv.ParseUint(cmd[1], 10, 64)
-				processId, processIdErr := strconv.ParseUint(cmd[2], 10, 64)
-				if taskIdErr != nil || processIdErr != nil {
-					fmt.Fprintln(os.Stderr, "Error: task-id and process-id must be valid positive integers")
-					break
-				}
-				out <- input.NewStartProcess(uint(taskId), uint(processId))
-
-			case "stop":
-				if len(cmd) != 3 {
-					fmt.Fprintln(os.Stderr, "usage: stop <task-id> <process-id>")
-					break
-				}
-				taskId, taskIdErr := strconv.ParseUint(cmd[1], 10, 64)
-				processId, processIdErr := strconv.ParseUint(cmd[2], 10, 64)
-				if taskIdErr != nil || processIdErr != nil {
-					fmt.Fprintln(os.Stderr, "Error: task-id and process-id must be valid positive integers")
-					break
-				}
-				out <- input.NewStopProcess(uint(taskId), uint(processId))
-
-			case "restart":
-				if len(cmd) != 3 {
-					fmt.Fprintln(os.Stderr, "usage: restart <task-id> <process-id>")
-					break
-				}
-				taskId, taskIdErr := strconv.ParseUint(cmd[1], 10, 64)
-				processId, processIdErr := strconv.ParseUint(cmd[2], 10, 64)
-				if taskIdErr != nil || processIdErr != nil {
-					fmt.Fprintln(os.Stderr, "Error: task-id and process-id must be valid positive integers")
+			} else if req, err := ParseCommand(cmd); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				if _, ok := req.(input.Reload); ok {
+					reloadInProgress.Add(1)
 				}
-				out <- input.NewRestartProcess(uint(taskId), uint(processId))
-
-			case "reload":
-				reloadInProgress.Add(1)
-				out <- input.NewReload()
-
-			case "shutdown":
-				out <- input.NewShutdown()
-
-			default:
-				fmt.Printf("invalid command: %s (type `help` to get a list of available commands)\n", cmd[0])
+				out <- req
 			}
 			commandOk.Done()
 
@@ -217,27 +173,13 @@ func StartShell(in <-chan output.Message, out chan<- input.Message) {
 				return
 			}
 			fmt.Print("\033[2K\r")
-			switch res.(type) {
-			case output.Status:
-				res := res.(output.Status)
-				for _, task := range res.Tasks() {
-					fmt.Printf("%d -- %s\n", task.TaskId(), task.Name())
-					for _, proc := range task.Processes() {
-						fmt.Printf("  %d -- %s\n", proc.ProcessId(), proc.Value())
-					}
-				}
-
-			case output.Reload:
+			if _, ok := res.(output.Reload); ok {
 				reloadInProgress.Done()
-				switch res.(type) {
-				case helpers.Success:
-					fmt.Println("Successfully reloaded configuration.")
-				case helpers.Failure:
-					fmt.Printf("Failed to reload configuration: %s.\n", res.(output.ReloadFailure).Reason())
-				}
-
-			case output.BadRequest:
-				fmt.Fprintln(os.Stderr, "Invalid request.")
+			}
+			if message, failed := FormatResponse(res); failed {
+				fmt.Fprintln(os.Stderr, message)
+			} else if len(message) != 0 {
+				fmt.Println(message)
 			}
 			DisplayCommand()
 		}